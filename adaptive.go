@@ -0,0 +1,79 @@
+package backoff
+
+import (
+	"math"
+	"time"
+)
+
+// SetAdaptive switches the Backoff to adaptive mode, in which the
+// effective interval widens with the latency of the backend, as
+// reported by Observe, so that a slow but working backend is given
+// more room between tries. The latencies are combined into an
+// exponentially weighted moving average with weight alpha, so that
+// each new latency x updates the average to
+//
+//	avg = alpha*x + (1-alpha)*avg
+//
+// and the effective interval is the larger of the interval and
+// scale*avg. Each duration grows from the effective interval as usual,
+// capped at the max duration.
+//
+// Panics if alpha is not greater than 0 and at most 1, or if scale is
+// not greater than 0.
+func (b *Backoff) SetAdaptive(alpha, scale float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !(alpha > 0 && alpha <= 1) {
+		panic("backoff: adaptive alpha is not between 0 and 1")
+	}
+
+	if !(scale > 0) {
+		panic("backoff: adaptive scale <= 0")
+	}
+
+	b.adaptAlpha = alpha
+	b.adaptScale = scale
+}
+
+// Observe records the latency of a request to the backend, for use by
+// adaptive mode; see SetAdaptive. The first latency observed
+// initialises the moving average. Latencies are recorded even if
+// adaptive mode is off, and are kept by Reset. It is safe to call
+// Observe concurrently with Duration.
+func (b *Backoff) Observe(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if latency < 0 {
+		latency = 0
+	}
+
+	if b.latency == 0 || b.adaptAlpha == 0 {
+		b.latency = latency
+		return
+	}
+
+	avg := b.adaptAlpha*float64(latency) + (1-b.adaptAlpha)*float64(b.latency)
+	b.latency = time.Duration(math.Round(avg))
+}
+
+// adapt scales t, which is based on the interval, to be based on the
+// effective interval instead.
+//
+// requires b to be locked.
+func (b *Backoff) adapt(t time.Duration) time.Duration {
+	effective := b.adaptScale * float64(b.latency)
+	if effective <= float64(b.interval) {
+		return t
+	}
+
+	// Saturate at the max duration; this also covers the product
+	// overflowing to +Inf.
+	f := float64(t) * effective / float64(b.interval)
+	if f >= float64(b.maxDuration) {
+		return b.maxDuration
+	}
+
+	return time.Duration(f)
+}
@@ -0,0 +1,100 @@
+package backoff
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Ensure that the moving average of the observed latencies widens the
+// effective interval in adaptive mode.
+func TestAdaptive(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.SetAdaptive(0.5, 2)
+
+	// Latencies below half the interval don't widen it.
+	b.Observe(200 * time.Millisecond)
+	if dur := b.Duration(); dur != time.Second {
+		t.Fatalf("want duration=%s, have %s", time.Second, dur)
+	}
+
+	// The average is now (200ms+3.8s)/2 = 2s, so the effective
+	// interval is 4s.
+	b.Observe(3800 * time.Millisecond)
+	if dur := b.Duration(); dur != 8*time.Second {
+		t.Fatalf("want duration=%s, have %s", 8*time.Second, dur)
+	}
+
+	for i := 0; i < 5; i++ {
+		_ = b.Duration()
+	}
+
+	if dur := b.Duration(); dur != time.Minute {
+		t.Fatalf("want duration=%s, have %s", time.Minute, dur)
+	}
+}
+
+// Ensure that latencies are recorded, but not used, outside adaptive
+// mode.
+func TestObserveNotAdaptive(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.Observe(time.Hour)
+	if dur := b.Duration(); dur != time.Second {
+		t.Fatalf("want duration=%s, have %s", time.Second, dur)
+	}
+
+	if b.latency != time.Hour {
+		t.Fatalf("expected latency=%s, have %s", time.Hour, b.latency)
+	}
+}
+
+// Ensure that a copy of an adaptive backoff keeps the moving average of
+// the latency, so that it continues with the same effective interval.
+func TestAdaptiveCopy(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.SetAdaptive(1, 2)
+	b.Observe(2 * time.Second)
+
+	c := b.Copy()
+	if have, want := c.Duration(), b.Duration(); have != want {
+		t.Fatalf("want duration=%s for the copy, have %s", want, have)
+	}
+}
+
+// Ensure that Observe can be called concurrently with Duration; this
+// is mostly useful when run with the race detector.
+func TestObserveConcurrent(t *testing.T) {
+	b := NewWithoutJitter(time.Second, time.Millisecond)
+	b.SetAdaptive(0.2, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			b.Observe(time.Duration(i) * time.Microsecond)
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		if dur := b.Duration(); dur <= 0 || dur > time.Second {
+			t.Fatalf("expected 0 < duration <= %s, have %s", time.Second, dur)
+		}
+	}
+	wg.Wait()
+}
+
+// SetAdaptive should reject parameters outside their ranges.
+func TestAdaptiveInvalid(t *testing.T) {
+	params := [][2]float64{{0, 1}, {1.5, 1}, {0.5, 0}, {0.5, -1}}
+	for _, p := range params {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected SetAdaptive(%v, %v) to panic", p[0], p[1])
+				}
+			}()
+			new(Backoff).SetAdaptive(p[0], p[1])
+		}()
+	}
+}
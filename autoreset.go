@@ -0,0 +1,65 @@
+package backoff
+
+import (
+	"sync"
+	"time"
+)
+
+// StartAutoReset starts a goroutine that resets the backoff, as with
+// Reset, once Duration hasn't been called for idle, so that a
+// long-lived backoff forgets old failures when things go quiet. Unlike
+// SetDecay, this doesn't wait for the next call to Duration. The check
+// is made every idle/4, so the reset happens between idle and 5/4 of
+// idle after the last call.
+//
+// The returned function stops the goroutine, and returns once it has
+// exited. It may be called more than once.
+//
+// Panics if idle is not positive.
+func (b *Backoff) StartAutoReset(idle time.Duration) (stop func()) {
+	if idle <= 0 {
+		panic("backoff: auto reset idle time <= 0")
+	}
+
+	period := idle / 4
+	if period <= 0 {
+		period = idle
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		seen, changed := b.Tries(), time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				b.ResetIf(func(tries uint64) bool {
+					if tries != seen {
+						seen, changed = tries, now
+						return false
+					}
+
+					if tries == 0 || now.Sub(changed) < idle {
+						return false
+					}
+
+					seen = 0
+					return true
+				})
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
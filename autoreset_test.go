@@ -0,0 +1,50 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that the backoff is reset once it has been idle, but not while
+// it is in use.
+func TestStartAutoReset(t *testing.T) {
+	const idle = 40 * time.Millisecond
+
+	b := NewWithoutJitter(max, interval)
+	stop := b.StartAutoReset(idle)
+	defer stop()
+
+	for i := 0; i < 10; i++ {
+		_ = b.Duration()
+		time.Sleep(idle / 8)
+	}
+
+	if b.Tries() != 10 {
+		t.Fatalf("expected tries=10 while in use, have tries=%d", b.Tries())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for b.Tries() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the backoff to be reset once idle, have tries=%d", b.Tries())
+		}
+		time.Sleep(idle / 8)
+	}
+}
+
+// Ensure that stop can be called more than once, and that the backoff
+// isn't reset after it has been called.
+func TestStartAutoResetStop(t *testing.T) {
+	const idle = 10 * time.Millisecond
+
+	b := NewWithoutJitter(max, interval)
+	stop := b.StartAutoReset(idle)
+	stop()
+	stop()
+
+	_ = b.Duration()
+	time.Sleep(5 * idle)
+	if b.Tries() != 1 {
+		t.Fatalf("expected tries=1 after stop, have tries=%d", b.Tries())
+	}
+}
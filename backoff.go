@@ -10,14 +10,17 @@
 // backoff is configured with a maximum duration that will not be
 // exceeded.
 //
-// The `New` function will attempt to use the system's cryptographic
-// random number generator to seed a Go math/rand random number
-// source. If this fails, the package will panic on startup.
+// Each Backoff has its own Go math/rand random number source, which
+// is seeded from the system's cryptographic random number generator
+// when the Backoff is created by `New`, or when a zero-value Backoff is
+// first used. If this fails, the Unix timestamp, in nanoseconds, is
+// used as the seed instead.
 package backoff
 
 import (
-	"crypto/rand"
+	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
 	mrand "math/rand"
@@ -25,9 +28,6 @@ import (
 	"time"
 )
 
-var prngMu sync.Mutex
-var prng *mrand.Rand
-
 // DefaultInterval is used when a Backoff is initialised with a
 // zero-value Interval.
 var DefaultInterval = 5 * time.Minute
@@ -36,13 +36,85 @@ var DefaultInterval = 5 * time.Minute
 // delay for.
 var DefaultMaxDuration = 6 * time.Hour
 
+// DefaultFactor is the factor by which the duration grows on each try
+// when a Backoff is initialised without one; see SetFactor. It must be
+// greater than 1.
+var DefaultFactor = 2.0
+
 // A Backoff contains the information needed to intelligently backoff
 // and retry operations using an exponential backoff algorithm. It should
 // be initialised with a call to `New`.
 //
-// Only use a Backoff from a single goroutine, it is not safe for concurrent
-// access.
+// A Backoff is safe for concurrent use by multiple goroutines.
 type Backoff struct {
+	mu sync.Mutex
+
+	config
+
+	// n is the exponent of the next duration, and tries is the
+	// number of tries since the last reset. They are the same
+	// unless Retreat has been called.
+	n     uint64
+	tries uint64
+
+	// total is the sum of the durations returned since the last
+	// reset.
+	total time.Duration
+
+	// last is the last duration returned.
+	last time.Duration
+
+	// atCeiling is true if the last duration, before jitter, had
+	// reached the max duration.
+	atCeiling bool
+
+	lastTry time.Time
+
+	// prev is the previous duration returned when using
+	// decorrelated jitter.
+	prev time.Duration
+
+	// rng is the source of randomness for jitter.
+	rng RandSource
+
+	// lastSuccess is the time of the last call to MarkSuccess.
+	lastSuccess time.Time
+
+	// hist records the durations returned, if EnableStats has been
+	// called.
+	hist *histogram
+
+	// latency is the moving average of the latencies passed to
+	// Observe, or zero if none have been.
+	latency time.Duration
+
+	// offset is the upper bound of the random offset added to the
+	// next duration, set by SetInitialOffset. It is cleared once
+	// the offset has been added.
+	offset time.Duration
+
+	// clock returns the current time. If it is nil, time.Now is
+	// used.
+	clock func() time.Time
+
+	// virtual is the virtual clock of a Backoff created by
+	// NewSimulation, and nil otherwise.
+	virtual *virtualClock
+
+	// onRetry is called each time a duration is returned.
+	onRetry func(attempt uint64, d time.Duration)
+
+	// log is called each time a duration is returned, if a logger
+	// has been set with SetLogger.
+	log func(attempt uint64, d time.Duration, atCeiling bool)
+
+	// shared coordinates the callers of SharedWait.
+	shared sharedWait
+}
+
+// config holds the configuration of a Backoff, as opposed to its
+// state.
+type config struct {
 	// maxDuration is the largest possible duration that can be
 	// returned from a call to Duration.
 	maxDuration time.Duration
@@ -61,8 +133,76 @@ type Backoff struct {
 	// the last try.
 	decay time.Duration
 
-	n       uint64
-	lastTry time.Time
+	// factor is the base of the exponential growth of the
+	// duration. If it is zero, DefaultFactor is used.
+	factor float64
+
+	// minDuration is the smallest possible duration that can be
+	// returned from a call to Duration, after jitter is applied.
+	minDuration time.Duration
+
+	// maxTries is the number of tries after which the backoff is
+	// exhausted. If it is zero, the backoff is never exhausted.
+	maxTries uint64
+
+	// breaker is the number of tries after which the circuit breaker
+	// is open. If it is zero, there is no breaker.
+	breaker uint64
+
+	// budget is the total duration after which the budget is
+	// exceeded. If it is zero, there is no budget.
+	budget time.Duration
+
+	// jitterMode selects the jitter algorithm used when noJitter
+	// is false.
+	jitterMode jitterMode
+
+	// jitterFactor is the fraction of the duration that is
+	// randomised when using partial jitter.
+	jitterFactor float64
+
+	// nonZeroJitter shifts the random part of the jitter up by one,
+	// so that a jittered duration is never zero.
+	nonZeroJitter bool
+
+	// secureRandom selects a source of randomness backed by
+	// crypto/rand, set by SetSecureRandom, so that copies of the
+	// backoff use one too.
+	secureRandom bool
+
+	// jitterCap caps the upper bound of a jittered duration. If it
+	// is zero, only the max duration applies.
+	jitterCap time.Duration
+
+	// monotonic prevents a duration without jitter from being
+	// shorter than the last one.
+	monotonic bool
+
+	// quickRecovery shortens the first duration after a recent
+	// success; see SetQuickRecovery.
+	quickRecovery bool
+
+	// firstDelay caps the first duration after the backoff is
+	// created or reset. If it is zero, the first duration is not
+	// capped.
+	firstDelay time.Duration
+
+	// maxExponent caps the exponent used to compute the duration.
+	// If it is zero, the exponent is not capped.
+	maxExponent uint64
+
+	// growth selects how the duration grows with the number of
+	// tries.
+	growth growthMode
+
+	// switchAfter is the number of linear durations before
+	// switching to exponential growth, when using phased growth.
+	switchAfter uint64
+
+	// adaptAlpha and adaptScale configure adaptive mode; see
+	// SetAdaptive. If adaptScale is zero, adaptive mode is off.
+	adaptAlpha float64
+	adaptScale float64
 }
 
 // New creates a new backoff with the specified max duration and
@@ -75,8 +215,10 @@ func New(max time.Duration, interval time.Duration) *Backoff {
 	}
 
 	b := &Backoff{
-		maxDuration: max,
-		interval:    interval,
+		config: config{
+			maxDuration: max,
+			interval:    interval,
+		},
 	}
 	b.setup()
 	return b
@@ -90,21 +232,34 @@ func NewWithoutJitter(max time.Duration, interval time.Duration) *Backoff {
 	return b
 }
 
-func init() {
+// NewWithSeed works similarly to New, except that the created Backoff
+// uses a math/rand source seeded with seed, rather than one seeded
+// from crypto/rand, so that its jittered durations are reproducible.
+func NewWithSeed(max time.Duration, interval time.Duration, seed int64) *Backoff {
+	b := New(max, interval)
+	b.rng = mrand.New(mrand.NewSource(seed))
+	return b
+}
+
+// newRand returns a math/rand PRNG seeded from crypto/rand, or from
+// the current time if crypto/rand fails, rather than panicking; jitter
+// only needs to differ between Backoffs, not to be unpredictable.
+func newRand() *mrand.Rand {
 	var buf [8]byte
 	var n int64
 
-	_, err := io.ReadFull(rand.Reader, buf[:])
+	_, err := io.ReadFull(randReader, buf[:])
 	if err != nil {
-		panic(err.Error())
+		n = time.Now().UnixNano()
+	} else {
+		n = int64(binary.LittleEndian.Uint64(buf[:]))
 	}
 
-	n = int64(binary.LittleEndian.Uint64(buf[:]))
-
 	src := mrand.NewSource(n)
-	prng = mrand.New(src)
+	return mrand.New(src)
 }
 
+// requires b to be locked.
 func (b *Backoff) setup() {
 	if b.interval == 0 {
 		b.interval = DefaultInterval
@@ -113,60 +268,1093 @@ func (b *Backoff) setup() {
 	if b.maxDuration == 0 {
 		b.maxDuration = DefaultMaxDuration
 	}
+
+	if b.factor == 0 {
+		b.factor = DefaultFactor
+	}
+
+	if b.rng == nil {
+		if b.secureRandom {
+			b.rng = secureSource{}
+		} else {
+			b.rng = newRand()
+		}
+	}
+}
+
+// setConfig replaces the configuration of the backoff, replacing its
+// source of randomness too if it doesn't match the secureRandom flag
+// of c.
+//
+// requires b to be locked.
+func (b *Backoff) setConfig(c config) {
+	b.config = c
+	if _, ok := b.rng.(secureSource); ok != b.secureRandom {
+		b.rng = nil
+	}
+	b.setup()
 }
 
 // Duration returns a time.Duration appropriate for the backoff,
 // incrementing the attempt counter.
 func (b *Backoff) Duration() time.Duration {
+	b.mu.Lock()
+	d := b.next()
+	b.unlockNotify(d)
+	return d
+}
+
+// Next works like Duration, but also returns the attempt counter
+// after it has been incremented. Both values are read under a single
+// lock, so the attempt number always matches the duration.
+func (b *Backoff) Next() (attempt uint64, d time.Duration) {
+	b.mu.Lock()
+	d = b.next()
+	attempt = b.tries
+	b.unlockNotify(d)
+	return attempt, d
+}
+
+// NextTime works like Duration, but returns the time at which to
+// retry, that is, the current time plus the duration, for schedulers
+// that store absolute times. The current time is read under the same
+// lock as the duration is computed.
+func (b *Backoff) NextTime() time.Time {
+	b.mu.Lock()
+	d := b.next()
+	at := b.now().Add(d)
+	b.unlockNotify(d)
+	return at
+}
+
+// DurationWith works like Duration, but computes the duration as if
+// the interval were interval, for this call only; the configured
+// interval is unchanged and used again by the next call. The attempt
+// counter is incremented as usual. A zero interval uses the configured
+// interval.
+//
+// Panics if interval is negative.
+func (b *Backoff) DurationWith(interval time.Duration) time.Duration {
+	if interval < 0 {
+		panic("backoff: interval < 0")
+	}
+
+	b.mu.Lock()
+	b.setup()
+	configured := b.interval
+	if interval != 0 {
+		b.interval = interval
+	}
+	d := b.next()
+	b.interval = configured
+	b.unlockNotify(d)
+	return d
+}
+
+// DurationScaled works like Duration, but multiplies the duration by
+// factor, such as a signal derived from the depth of a work queue, so
+// that the caller can speed up or slow down the backoff on each call.
+// The attempt counter is incremented exactly as by Duration. The scaled
+// duration is clamped to the max duration, and to the min duration if
+// one has been set with SetMin; the factor is applied after jitter.
+//
+// Panics if factor is negative or NaN.
+func (b *Backoff) DurationScaled(factor float64) time.Duration {
+	if factor < 0 || math.IsNaN(factor) {
+		panic("backoff: scale factor < 0")
+	}
+
+	b.mu.Lock()
+	t := b.advance()
+	scaled := float64(t) * factor
+	if scaled >= float64(b.maxDuration) {
+		t = b.maxDuration
+	} else {
+		t = b.floor(time.Duration(scaled))
+	}
+
+	d := b.record(t)
+	b.unlockNotify(d)
+	return d
+}
+
+// DurationWithHint works like Duration, but returns at least hint,
+// such as the value of a Retry-After header sent by a server. The
+// result is still capped at the max duration. A zero hint is ignored.
+//
+// If jitter is enabled, the hint is compared with the jittered
+// duration; that is, the hint takes precedence whenever the random
+// value is smaller than it.
+func (b *Backoff) DurationWithHint(hint time.Duration) time.Duration {
+	b.mu.Lock()
+	t := b.advance()
+	if t < hint {
+		t = hint
+	}
+
+	if t > b.maxDuration {
+		t = b.maxDuration
+	}
+
+	d := b.record(t)
+	b.unlockNotify(d)
+	return d
+}
+
+// DurationContext works like Duration, but if ctx has a deadline, the
+// duration is clamped to the time remaining until it, as there is no
+// point waiting past the deadline only to fail. If the deadline has
+// already passed, it returns 0. If ctx has no deadline, it behaves
+// exactly like Duration.
+func (b *Backoff) DurationContext(ctx context.Context) time.Duration {
+	b.mu.Lock()
+	t := b.advance()
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := deadline.Sub(b.now())
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		if t > remaining {
+			t = remaining
+		}
+	}
+
+	d := b.record(t)
+	b.unlockNotify(d)
+	return d
+}
+
+// SetOnRetry sets a function to be called each time the backoff
+// returns a duration, with the number of tries so far (including this
+// one) and the duration. It is called after b has been unlocked, but
+// it must not use b itself, as the state of b may have changed by the
+// time it is called. A nil fn removes the callback.
+func (b *Backoff) SetOnRetry(fn func(attempt uint64, d time.Duration)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.onRetry = fn
+}
+
+// unlockNotify unlocks b, and then calls the OnRetry callback and the
+// logger, if any, for the duration d that was just returned.
+//
+// requires b to be locked.
+func (b *Backoff) unlockNotify(d time.Duration) {
+	fn, log, attempt, atCeiling := b.onRetry, b.log, b.tries, b.atCeiling
+	b.mu.Unlock()
+
+	if fn != nil {
+		fn(attempt, d)
+	}
+
+	if log != nil {
+		log(attempt, d, atCeiling)
+	}
+}
+
+// Tries returns the number of attempts made since the backoff was
+// created or last reset.
+func (b *Backoff) Tries() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tries
+}
+
+// Exponent returns the exponent that the next duration will be based
+// on. It starts out the same as Tries, but unlike the number of tries,
+// it is decremented by Retreat. The duration stops growing at the max
+// duration (see AtCeiling) or the max exponent (see SetMaxExponent),
+// even though the exponent itself keeps increasing.
+func (b *Backoff) Exponent() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.n
+}
+
+// CeilingAttempt returns the smallest exponent at which the duration,
+// before jitter, reaches the max duration; that is, the attempt,
+// counting from zero, after which the backoff plateaus. With the
+// default factor of 2, it is the smallest n for which
+// interval * 2^n >= max. It takes the growth mode, factor and max
+// exponent into account, and returns math.MaxUint64 if the duration
+// never reaches the max duration. It doesn't consume any attempts.
+func (b *Backoff) CeilingAttempt() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	if b.duration(0) >= b.maxDuration {
+		return 0
+	}
+
+	if b.duration(math.MaxUint64) < b.maxDuration {
+		return math.MaxUint64
+	}
+
+	// The duration is non-decreasing, so search for the first
+	// exponent that reaches the max duration.
+	lo, hi := uint64(0), uint64(math.MaxUint64)
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if b.duration(mid) >= b.maxDuration {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return hi
+}
+
+// SetTries sets both the number of tries and the exponent to n, as if
+// n tries had already been made, so that the next duration reflects
+// failures that happened elsewhere. Durations for large values of n
+// saturate at the max duration as usual. To restore the rest of the
+// state as well, use LoadState.
+func (b *Backoff) SetTries(n uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tries = n
+	b.n = n
+}
+
+// SetMaxTries sets the number of tries after which the backoff is
+// exhausted; see Exhausted. A value of 0, the default, means that the
+// backoff is never exhausted.
+func (b *Backoff) SetMaxTries(n uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.maxTries = n
+}
+
+// Exhausted returns true once the number of tries has reached the
+// limit set with SetMaxTries. Duration will still return durations
+// once the backoff is exhausted; it is up to the caller to stop
+// retrying.
+func (b *Backoff) Exhausted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.exhausted()
+}
+
+// SetBreaker pairs the backoff with a circuit breaker that opens once
+// the number of tries reaches threshold; see Open. Resetting the
+// backoff, such as after a success, closes the breaker. A value of 0,
+// the default, means the breaker never opens.
+func (b *Backoff) SetBreaker(threshold uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.breaker = threshold
+}
+
+// Open returns true once the number of tries has reached the threshold
+// set with SetBreaker, signalling that callers should fail fast rather
+// than wait for the next duration. Like Exhausted, it doesn't stop
+// Duration from returning durations.
+func (b *Backoff) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.breaker != 0 && b.tries >= b.breaker
+}
+
+// AtCeiling returns true if the last duration returned by Duration,
+// before jitter was applied, had reached the max duration; that is,
+// the backoff has saturated and will not grow any further.
+func (b *Backoff) AtCeiling() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.atCeiling
+}
+
+// LastDuration returns the last duration returned by Duration, or zero
+// if no duration has been returned since the backoff was created or
+// last reset. This is useful with jitter, where the duration can't be
+// recomputed.
+func (b *Backoff) LastDuration() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.last
+}
+
+// Stats is a snapshot of the state of a Backoff.
+type Stats struct {
+	// Tries is the number of tries since the last reset.
+	Tries uint64
+
+	// CurrentExponent is the exponent that the next duration will
+	// be based on. It is the same as Tries unless Retreat has
+	// been called.
+	CurrentExponent uint64
+
+	// LastDuration is the last duration returned, or zero if no
+	// duration has been returned since the last reset.
+	LastDuration time.Duration
+
+	// AtCeiling is true if the last duration had reached the max
+	// duration; see Backoff.AtCeiling.
+	AtCeiling bool
+}
+
+// Snapshot returns the current state of the backoff. All of the
+// values are read under a single lock, so they are consistent with
+// each other.
+func (b *Backoff) Snapshot() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Stats{
+		Tries:           b.tries,
+		CurrentExponent: b.n,
+		LastDuration:    b.last,
+		AtCeiling:       b.atCeiling,
+	}
+}
+
+// Remaining returns the number of tries left before the backoff is
+// exhausted, or math.MaxUint64 if no limit has been set with
+// SetMaxTries.
+func (b *Backoff) Remaining() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.maxTries == 0:
+		return math.MaxUint64
+	case b.tries >= b.maxTries:
+		return 0
+	default:
+		return b.maxTries - b.tries
+	}
+}
+
+// requires b to be locked.
+func (b *Backoff) exhausted() bool {
+	return b.maxTries != 0 && b.tries >= b.maxTries
+}
+
+// SetBudget sets the total amount of time that the backoff may spend
+// backing off; see BudgetExceeded. The budget is measured as the sum
+// of the durations returned by Duration, rather than the wall-clock
+// time elapsed since the first try, so it is unaffected by time spent
+// in the operation being retried. A value of 0, the default, means
+// that there is no budget. Panics if total is negative.
+func (b *Backoff) SetBudget(total time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if total < 0 {
+		panic("backoff: budget < 0")
+	}
+
+	b.budget = total
+}
+
+// BudgetExceeded returns true once the sum of the durations returned
+// by Duration since the last reset has reached the budget set with
+// SetBudget.
+func (b *Backoff) BudgetExceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.budgetExceeded()
+}
+
+// requires b to be locked.
+func (b *Backoff) budgetExceeded() bool {
+	return b.budget != 0 && b.total >= b.budget
+}
+
+// TotalElapsed returns the sum of the durations returned since the
+// backoff was created or last reset, including by decay. This is the
+// time that the caller planned to spend backing off, rather than the
+// wall-clock time elapsed since the first try.
+func (b *Backoff) TotalElapsed() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.total
+}
+
+// DurationOrAbort works like Duration, unless the backoff is exhausted
+// (see SetMaxTries) or its budget has been exceeded (see SetBudget), in
+// which case it returns false without incrementing the attempt
+// counter. The check and the duration are computed under a single
+// lock, so that another goroutine can't use up the last try in
+// between.
+func (b *Backoff) DurationOrAbort() (time.Duration, bool) {
+	_, d, ok := b.nextOrAbort()
+	return d, ok
+}
+
+// nextOrAbort works like Next, unless the backoff is exhausted or its
+// budget has been exceeded, in which case it returns false.
+func (b *Backoff) nextOrAbort() (attempt uint64, d time.Duration, ok bool) {
+	b.mu.Lock()
+	if b.exhausted() || b.budgetExceeded() {
+		b.mu.Unlock()
+		return 0, 0, false
+	}
+
+	d = b.next()
+	attempt = b.tries
+	b.unlockNotify(d)
+	return attempt, d, true
+}
+
+// String returns a description of the backoff's configuration and
+// attempt counter, such as
+//
+//	Backoff{interval=5m0s, max=6h0m0s, tries=3, jitter=true}
+func (b *Backoff) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	return fmt.Sprintf("Backoff{interval=%s, max=%s, tries=%d, jitter=%t}",
+		b.interval, b.maxDuration, b.tries, !b.noJitter)
+}
+
+// Clone returns a new backoff with the same configuration as b. The
+// clone has its own attempt counter, starting from zero, and its own
+// source of randomness; it does not share any state with b. To also
+// keep the current position in the backoff, use Copy.
+func (b *Backoff) Clone() *Backoff {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := &Backoff{
+		config:  b.config,
+		clock:   b.clock,
+		virtual: b.virtual,
+		onRetry: b.onRetry,
+		log:     b.log,
+	}
+	c.setup()
+	return c
+}
+
+// Copy returns a new backoff with the same configuration and the same
+// state as b, such as the number of tries and the last duration, so
+// that it continues from the same point; Clone, by contrast, starts
+// from zero. The copy is independent of b from then on, with its own
+// lock and its own source of randomness, so calls to one don't affect
+// the other.
+func (b *Backoff) Copy() *Backoff {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := &Backoff{
+		n:           b.n,
+		tries:       b.tries,
+		total:       b.total,
+		last:        b.last,
+		atCeiling:   b.atCeiling,
+		config:      b.config,
+		lastTry:     b.lastTry,
+		lastSuccess: b.lastSuccess,
+		prev:        b.prev,
+		latency:     b.latency,
+		offset:      b.offset,
+		clock:       b.clock,
+		virtual:     b.virtual,
+		onRetry:     b.onRetry,
+		log:         b.log,
+	}
+	c.setup()
+	return c
+}
+
+// next computes the next duration and increments the attempt
+// counter.
+//
+// requires b to be locked.
+func (b *Backoff) next() time.Duration {
+	return b.record(b.advance())
+}
+
+// advance computes the next duration and increments the attempt
+// counter. The caller must pass the duration it returns, possibly
+// adjusted, to record.
+//
+// requires b to be locked.
+func (b *Backoff) advance() time.Duration {
 	b.setup()
 
 	b.decayN()
 
+	first := b.tries == 0
 	t := b.duration(b.n)
+	b.atCeiling = t >= b.maxDuration
+	if first && b.quickRecovery {
+		t = b.recovering(t)
+	}
 
 	if b.n < math.MaxUint64 {
 		b.n++
 	}
 
+	if b.tries < math.MaxUint64 {
+		b.tries++
+	}
+
 	if !b.noJitter {
-		prngMu.Lock()
-		t = time.Duration(prng.Int63n(int64(t)))
-		prngMu.Unlock()
+		t = b.applyJitter(t)
+	}
+
+	if b.offset != 0 {
+		t = b.addOffset(t)
+	}
+
+	return b.capFirst(b.hold(b.floor(t)), first)
+}
+
+// capFirst caps t at the first delay, if one is set and t is the first
+// duration since the backoff was created or reset.
+//
+// requires b to be locked.
+func (b *Backoff) capFirst(t time.Duration, first bool) time.Duration {
+	if first && b.firstDelay != 0 && t > b.firstDelay {
+		return b.firstDelay
+	}
+
+	return t
+}
+
+// record accounts for t being returned to the caller.
+//
+// requires b to be locked.
+func (b *Backoff) record(t time.Duration) time.Duration {
+	if b.total < math.MaxInt64-t {
+		b.total += t
+	} else {
+		b.total = math.MaxInt64
 	}
 
+	b.last = t
+	if b.hist != nil {
+		b.hist.add(t)
+	}
 	return t
 }
 
 // requires b to be locked.
-func (b *Backoff) duration(n uint64) (t time.Duration) {
-	// Saturate pow
-	pow := time.Duration(math.MaxInt64)
-	if n < 63 {
-		pow = 1 << n
+func (b *Backoff) floor(t time.Duration) time.Duration {
+	if t < b.minDuration {
+		return b.minDuration
 	}
 
-	t = b.interval * pow
-	if t/pow != b.interval || t > b.maxDuration {
-		t = b.maxDuration
+	return t
+}
+
+// hold returns the last duration instead of t if the backoff is
+// monotonic and t is shorter.
+//
+// requires b to be locked.
+func (b *Backoff) hold(t time.Duration) time.Duration {
+	if b.monotonic && b.noJitter && t < b.last {
+		return b.last
+	}
+
+	return t
+}
+
+// Peek returns the duration that the next call to Duration would be
+// based on, without incrementing the attempt counter.
+//
+// If jitter is enabled, Peek returns the upper bound of the next
+// duration rather than a random sample, as the random value is only
+// meaningful once it is actually used. Likewise, the upper bound of an
+// offset set by SetInitialOffset is included if it hasn't been used
+// yet.
+func (b *Backoff) Peek() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+
+	decayed := b.decayed()
+	n, prev := b.n, b.prev
+	if decayed {
+		n, prev = 0, 0
+	}
+
+	var t time.Duration
+	if !b.noJitter && b.jitterMode == decorrelatedJitter {
+		t = b.decorrelatedBound(prev)
+	} else {
+		t = b.peekBound(b.duration(n))
+	}
+
+	// A pending initial offset is random, so its upper bound is
+	// added, as with jitter.
+	if b.offset != 0 {
+		if b.offset > b.maxDuration-t {
+			t = b.maxDuration
+		} else {
+			t += b.offset
+		}
+	}
+
+	t = b.floor(t)
+	if !decayed {
+		t = b.hold(t)
+	}
+
+	return b.capFirst(t, decayed || b.tries == 0)
+}
+
+// peekBound returns the upper bound of the jittered duration for the
+// exponential duration t; that is, t capped at the jitter cap, or the
+// top of the band if proportional jitter can return a longer one.
+//
+// requires b to be locked.
+func (b *Backoff) peekBound(t time.Duration) time.Duration {
+	if b.noJitter {
+		return t
+	}
+
+	if b.jitterCap != 0 && t > b.jitterCap {
+		t = b.jitterCap
+	}
+
+	if b.jitterMode == proportionalJitter {
+		_, hi := b.proportionalBand(t)
+		return hi
+	}
+
+	return t
+}
+
+// Schedule returns the next n durations that the backoff would
+// return without jitter, starting from its current state, without
+// incrementing the attempt counter. If decorrelated jitter is enabled,
+// the durations are the upper bounds of the jitter, assuming that each
+// duration is the largest possible.
+func (b *Backoff) Schedule(n int) []time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n <= 0 {
+		return nil
+	}
+
+	b.setup()
+	schedule := make([]time.Duration, n)
+	exp, prev := b.n, b.prev
+	for i := range schedule {
+		var t time.Duration
+		if !b.noJitter && b.jitterMode == decorrelatedJitter {
+			t = b.decorrelatedBound(prev)
+			prev = t
+		} else {
+			t = b.duration(exp)
+		}
+
+		if exp < math.MaxUint64 {
+			exp++
+		}
+		schedule[i] = b.capFirst(b.floor(t), i == 0 && b.tries == 0)
+	}
+
+	return schedule
+}
+
+// Wait sleeps for the next backoff duration, as returned by
+// Duration. It returns nil once the duration has elapsed, or ctx.Err()
+// if ctx is done first.
+func (b *Backoff) Wait(ctx context.Context) error {
+	return sleep(ctx, b.Duration())
+}
+
+// WaitReport works like Wait, but also returns how long it actually
+// waited, which is less than the planned duration if ctx is done
+// first. The planned duration can be compared against it with
+// LastDuration.
+func (b *Backoff) WaitReport(ctx context.Context) (time.Duration, error) {
+	d := b.Duration()
+	start := time.Now()
+	err := sleep(ctx, d)
+	return time.Since(start), err
+}
+
+// sleep waits for d, returning nil once it has elapsed, or ctx.Err()
+// if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// After waits for the next backoff duration, as returned by Duration,
+// and then sends the current time on the returned channel. Each call
+// creates a new timer and increments the attempt counter once.
+func (b *Backoff) After() <-chan time.Time {
+	return time.After(b.Duration())
+}
+
+// Schedule1 works like After, but also returns the duration that the
+// channel will wait for, so that it can be logged before waiting.
+func (b *Backoff) Schedule1() (time.Duration, <-chan time.Time) {
+	d := b.Duration()
+	return d, time.After(d)
+}
+
+// duration returns the nth duration, before jitter.
+//
+// requires b to be locked.
+func (b *Backoff) duration(n uint64) time.Duration {
+	t := b.grow(n)
+	if b.adaptScale != 0 {
+		t = b.adapt(t)
+	}
+
+	return t
+}
+
+// grow returns the nth duration according to the growth mode.
+//
+// requires b to be locked.
+func (b *Backoff) grow(n uint64) time.Duration {
+	if b.maxExponent != 0 && n > b.maxExponent {
+		n = b.maxExponent
+	}
+
+	switch b.growth {
+	case fibonacciGrowth:
+		return b.scale(fibonacci(n, b.scaleLimit()))
+	case linearGrowth:
+		if n == math.MaxUint64 {
+			return b.maxDuration
+		}
+		return b.scale(n + 1)
+	case constantGrowth:
+		return b.scale(1)
+	case rampDownGrowth:
+		return b.rampDown(n)
+	case phasedGrowth:
+		return b.phased(n)
+	}
+
+	return exponential(n, b.interval, b.maxDuration, b.factor)
+}
+
+// exponential returns factor^n * interval, capped at max; a factor of
+// 0 is treated as 2. Other factors use floating point, but a factor of
+// 2 uses an integer shift, so that it is exact. The product is
+// compared against max before it is computed, so it saturates at max
+// rather than overflowing however large the interval is.
+func exponential(n uint64, interval, max time.Duration, factor float64) time.Duration {
+	if factor != 0 && factor != 2 {
+		// Saturate at the max duration; this also covers
+		// the product overflowing to +Inf.
+		f := float64(interval) * math.Pow(factor, float64(n))
+		if f >= float64(max) {
+			return max
+		}
+
+		return time.Duration(f)
+	}
+
+	if n >= 63 || interval > max>>n {
+		return max
+	}
+
+	return interval << n
+}
+
+// Compute returns the duration for the given attempt, counting from
+// zero, of a backoff with the specified max duration and interval,
+// without needing a Backoff. It is useful when the number of attempts
+// is tracked elsewhere, such as in a database. Zero values may be used
+// to use the default values, the duration grows by DefaultFactor, and
+// it is capped at max as with Duration. If jitter is true, full
+// jitter is applied using a package-wide source seeded in the same way
+// as a Backoff's, which is safe for concurrent use.
+//
+// Panics if either max or interval is negative.
+func Compute(attempt uint64, interval, max time.Duration, jitter bool) time.Duration {
+	if max < 0 || interval < 0 {
+		panic("backoff: max or interval is negative")
+	}
+
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	if max == 0 {
+		max = DefaultMaxDuration
+	}
+
+	t := exponential(attempt, interval, max, DefaultFactor)
+	if !jitter {
+		return t
+	}
+
+	computeRand.once.Do(func() {
+		computeRand.rng = newRand()
+	})
+
+	computeRand.mu.Lock()
+	defer computeRand.mu.Unlock()
+
+	return time.Duration(computeRand.rng.Int63n(int64(t)))
+}
+
+// computeRand is the source of randomness for Compute; it is seeded on
+// first use rather than when the package is loaded. The top-level
+// math/rand functions can't be used, as before Go 1.20 they always
+// start from the same seed.
+var computeRand struct {
+	once sync.Once
+	mu   sync.Mutex
+	rng  *mrand.Rand
+}
+
+// Max calls Duration on each of the backoffs, advancing all of them,
+// and returns the longest duration, or zero if there are none. It is
+// useful for layered policies, such as a per-endpoint backoff and a
+// global one, where the most conservative should be respected.
+func Max(backoffs ...*Backoff) time.Duration {
+	var max time.Duration
+	for _, b := range backoffs {
+		if d := b.Duration(); d > max {
+			max = d
+		}
 	}
 
-	return
+	return max
 }
 
 // Reset resets the attempt counter of a backoff.
 //
 // It should be called when the rate-limited action succeeds.
+//
+// Calls to Reset and Duration from different goroutines are
+// serialised, each taking effect entirely before or after the other: a
+// Duration that is in progress when Reset is called completes first,
+// and its increment is then reset, while a Duration that starts after
+// Reset returns the first duration of the sequence. To reset only
+// based on the current number of tries, use ResetIf, as reading Tries
+// and then calling Reset races with other goroutines.
 func (b *Backoff) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastTry = time.Time{}
+	b.resetCounters()
+}
+
+// ResetReport works like Reset, and returns true if the backoff had
+// any tries to reset, so that callers can log only resets that changed
+// anything.
+func (b *Backoff) ResetReport() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := b.tries != 0
+	b.lastTry = time.Time{}
+	b.resetCounters()
+	return changed
+}
+
+// ResetIf calls fn with the number of tries and resets the backoff, as
+// with Reset, if it returns true; the check and the reset happen under
+// a single lock, so no other goroutine can change the number of tries
+// in between. It returns true if the backoff was reset. fn must not
+// call any methods on b.
+func (b *Backoff) ResetIf(fn func(tries uint64) bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !fn(b.tries) {
+		return false
+	}
+
 	b.lastTry = time.Time{}
+	b.resetCounters()
+	return true
+}
+
+// MarkSuccess works like Reset, and also records the time of the
+// success, for use by SetQuickRecovery.
+func (b *Backoff) MarkSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastTry = time.Time{}
+	b.resetCounters()
+	b.lastSuccess = b.now()
+}
+
+// SetQuickRecovery shortens the first duration after a recent
+// success, as recorded by MarkSuccess, on the grounds that a failure
+// soon after a success is more likely to be a brief hiccup than a
+// failure after a long run of them. If the failure comes less than
+// the interval after the success, the first duration is scaled by the
+// fraction of the interval that has elapsed since; a failure
+// immediately after a success is retried almost immediately, subject
+// to the minimum duration. The time since the success is measured
+// with the monotonic clock, as described for SetDecay.
+func (b *Backoff) SetQuickRecovery() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.quickRecovery = true
+}
+
+// recovering scales t by the fraction of the interval that has elapsed
+// since the last success, if it was less than the interval ago.
+//
+// requires b to be locked.
+func (b *Backoff) recovering(t time.Duration) time.Duration {
+	if b.lastSuccess.IsZero() {
+		return t
+	}
+
+	since := b.now().Sub(b.lastSuccess)
+	if since >= b.interval {
+		return t
+	}
+
+	if since < 0 {
+		since = 0
+	}
+
+	return time.Duration(float64(t) * float64(since) / float64(b.interval))
+}
+
+// ResetWithJitter works like Reset, except that the exponent is set to
+// a random value between 0 and 2 rather than to 0. When many clients
+// recover at the same time, this keeps their next backoffs out of
+// phase with each other. The number of tries is still reset to 0.
+func (b *Backoff) ResetWithJitter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	b.lastTry = time.Time{}
+	b.resetCounters()
+	b.n = uint64(b.rng.Int63n(3))
+}
+
+// Retreat decrements the exponent of the backoff by one, so that the
+// next duration is based on the one before the last. Unlike Reset, it
+// doesn't reset the number of tries, which makes it suitable for
+// easing off after a partial success without forgetting a long run of
+// failures.
+func (b *Backoff) Retreat() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.n > 0 {
+		b.n--
+	}
+}
+
+// requires b to be locked.
+func (b *Backoff) resetCounters() {
 	b.n = 0
+	b.tries = 0
+	b.prev = 0
+	b.total = 0
+	b.last = 0
+	b.atCeiling = false
+}
+
+// SetInterval changes the interval of the backoff, taking effect from
+// the next call to Duration. A zero value uses DefaultInterval. Panics
+// if interval is negative.
+func (b *Backoff) SetInterval(interval time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if interval < 0 {
+		panic("backoff: max or interval is negative")
+	}
+
+	b.interval = interval
+	b.setup()
+}
+
+// SetMaxDuration changes the max duration of the backoff, taking
+// effect from the next call to Duration; if it is lowered below the
+// current duration, the next duration is clamped to it. A zero value
+// uses DefaultMaxDuration.
+//
+// Panics if max is negative or less than the minimum duration.
+func (b *Backoff) SetMaxDuration(max time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if max < 0 {
+		panic("backoff: max or interval is negative")
+	}
+
+	if max == 0 {
+		max = DefaultMaxDuration
+	}
+
+	if max < b.minDuration {
+		panic("backoff: min is negative or greater than max")
+	}
+
+	b.maxDuration = max
+	b.setup()
+}
+
+// SetMaxExponent caps the exponent used to compute the duration at m,
+// so that the duration stops growing at interval * 2^m (or the
+// equivalent for the configured factor or growth mode), even if that
+// is below the max duration. The try counter keeps increasing. A value
+// of 0, the default, means that the exponent is only limited by the
+// max duration, and by the overflow guard that saturates the duration
+// at the max once interval * 2^n no longer fits in a time.Duration.
+func (b *Backoff) SetMaxExponent(m uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.maxExponent = m
 }
 
 // SetDecay sets the duration after which the try counter will be reset.
 // Panics if decay is smaller than 0.
 //
 // The decay only kicks in if at least the last backoff + decay has elapsed
-// since the last try.
+// since the last try. That is, if the last call to Duration returned 4s
+// and the decay is 10s, the next call to Duration will reset the try
+// counter if it is made more than 14s after the last one. A decay of 0
+// disables it.
+//
+// The time between tries is measured with the monotonic clock, as
+// time.Now and Time.Sub do, so that changes to the system's wall clock,
+// such as by NTP, neither reset the counter early nor keep it from
+// being reset. The one exception is the first try after LoadState, as
+// a time restored from a State only has a wall clock reading.
 func (b *Backoff) SetDecay(decay time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	if decay < 0 {
 		panic("backoff: decay < 0")
 	}
@@ -174,24 +1362,113 @@ func (b *Backoff) SetDecay(decay time.Duration) {
 	b.decay = decay
 }
 
+// SetFactor sets the factor by which the duration grows on each try,
+// so that the nth duration is interval * factor^n. The default factor
+// is DefaultFactor. Panics if factor is not greater than 1.
+func (b *Backoff) SetFactor(factor float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if factor <= 1 || math.IsNaN(factor) {
+		panic("backoff: factor <= 1")
+	}
+
+	b.factor = factor
+}
+
+// SetMin sets the minimum duration that will be returned by Duration.
+// Unlike the interval, which is the base of the exponential growth,
+// the minimum is applied after jitter, so that no jitter mode will
+// return a duration shorter than min.
+//
+// Panics if min is negative or greater than the max duration.
+func (b *Backoff) SetMin(min time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	if min < 0 || min > b.maxDuration {
+		panic("backoff: min is negative or greater than max")
+	}
+
+	b.minDuration = min
+}
+
+// SetFirstDelay caps the first duration returned after the backoff is
+// created, reset or decayed; the durations that follow are not
+// affected. A short first delay makes for a quick retry when the first
+// failure is a fluke. The cap takes precedence over the minimum
+// duration (see SetMin). A value of 0, the default, disables the cap.
+//
+// Panics if max is negative.
+func (b *Backoff) SetFirstDelay(max time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if max < 0 {
+		panic("backoff: first delay is negative")
+	}
+
+	b.firstDelay = max
+}
+
+// SetMonotonic ensures that, without jitter, no duration is shorter
+// than the one before it, even if the backoff is reconfigured, such as
+// with SetInterval, or Retreat is called. The sequence starts again
+// from the interval after Reset, or once the backoff decays. It has no
+// effect while jitter is enabled, as jittered durations aren't
+// monotonic.
+func (b *Backoff) SetMonotonic() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.monotonic = true
+}
+
 // requires b to be locked
 func (b *Backoff) decayN() {
 	if b.decay == 0 {
 		return
 	}
 
-	if b.lastTry.IsZero() {
-		b.lastTry = time.Now()
-		return
+	if b.decayed() {
+		b.resetCounters()
 	}
+	b.lastTry = b.now()
+}
 
-	lastDuration := b.duration(b.n - 1)
-	decayed := time.Since(b.lastTry) > lastDuration+b.decay
-	b.lastTry = time.Now()
+// decayed reports whether enough time has passed since the last try
+// for the attempt counter to be reset.
+//
+// requires b to be locked
+func (b *Backoff) decayed() bool {
+	if b.decay == 0 || b.lastTry.IsZero() {
+		return false
+	}
 
-	if !decayed {
-		return
+	// Both times carry a monotonic clock reading, unless they come
+	// from a custom clock or a restored State, so Sub is unaffected
+	// by changes to the wall clock. A clock that goes backwards
+	// gives a negative difference, which doesn't reset the counter.
+	// The threshold is based on the last duration returned rather
+	// than recomputed from n, which Retreat may have lowered.
+	return b.now().Sub(b.lastTry) > b.last+b.decay
+}
+
+// requires b to be locked.
+func (b *Backoff) now() time.Time {
+	if b.clock == nil {
+		return time.Now()
 	}
 
-	b.n = 0
+	return b.clock()
+}
+
+// setClock replaces the clock used by the backoff; it is intended for
+// tests.
+func (b *Backoff) setClock(clock func() time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clock = clock
 }
@@ -1,9 +1,15 @@
 package backoff
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -34,6 +40,30 @@ func TestDefaults(t *testing.T) {
 	}
 }
 
+// Ensure that DefaultFactor is used by backoffs created without a
+// factor.
+func TestDefaultFactor(t *testing.T) {
+	defer func(f float64) { DefaultFactor = f }(DefaultFactor)
+	DefaultFactor = 3
+
+	b := NewWithoutJitter(time.Hour, time.Second)
+	if b.factor != 3 {
+		t.Fatalf("expected factor=3, have factor=%v", b.factor)
+	}
+
+	for _, want := range []time.Duration{time.Second, 3 * time.Second, 9 * time.Second} {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%s, have %s", want, dur)
+		}
+	}
+
+	b = NewWithoutJitter(time.Hour, time.Second)
+	b.SetFactor(2)
+	if dur := b.Schedule(3)[2]; dur != 4*time.Second {
+		t.Fatalf("want duration=%s with an explicit factor, have %s", 4*time.Second, dur)
+	}
+}
+
 // Given a zero-value initialised Backoff, it should be transparently
 // setup.
 func TestSetup(t *testing.T) {
@@ -44,29 +74,984 @@ func TestSetup(t *testing.T) {
 	}
 }
 
-// Ensure that tries incremenets as expected.
-func TestTries(t *testing.T) {
-	b := NewWithoutJitter(5, 1)
+// Ensure that a Backoff can still be created and used if crypto/rand
+// fails, seeded from the current time instead.
+func TestSeedFallback(t *testing.T) {
+	defer func(r io.Reader) { randReader = r }(randReader)
+	randReader = iotest.ErrReader(errors.New("read failed"))
+
+	b := New(max, interval)
+	if b.rng == nil {
+		t.Fatal("expected the backoff to have a source of randomness")
+	}
+
+	for i := 0; i < 10; i++ {
+		if dur := b.Duration(); dur < 0 || dur > max {
+			t.Fatalf("expected 0 <= duration <= %s, have %s at i=%d", max, dur, i)
+		}
+	}
+}
+
+// Ensure that a multi-hour interval saturates at the max duration
+// rather than overflowing, however many attempts are made.
+func TestLargeIntervalOverflow(t *testing.T) {
+	maxes := []time.Duration{1000 * time.Hour, math.MaxInt64}
+	for _, m := range maxes {
+		b := NewWithoutJitter(m, 3*time.Hour)
+		var last time.Duration
+		for i := 0; i < 200; i++ {
+			dur := b.Duration()
+			if dur < last || dur > m {
+				t.Fatalf("expected %s <= duration <= %s, have %s at i=%d", last, m, dur, i)
+			}
+			last = dur
+		}
+
+		if last != m {
+			t.Fatalf("want duration=%s, have %s", m, last)
+		}
+
+		b = New(m, 3*time.Hour)
+		for i := 0; i < 200; i++ {
+			if dur := b.Duration(); dur < 0 || dur > m {
+				t.Fatalf("expected 0 <= duration <= %s, have %s at i=%d", m, dur, i)
+			}
+		}
+	}
+}
+
+// Ensure that the first delay caps only the first duration, until the
+// backoff is reset.
+func TestSetFirstDelay(t *testing.T) {
+	b := NewWithoutJitter(max, 4*interval)
+	b.SetFirstDelay(interval)
+
+	want := []time.Duration{interval, 8 * interval, max}
+	for i, w := range want {
+		if dur := b.Duration(); dur != w {
+			t.Fatalf("want duration=%s, have %s at i=%d", w, dur, i)
+		}
+	}
+
+	b.Reset()
+	if dur := b.Duration(); dur != interval {
+		t.Fatalf("want duration=%s after Reset, have %s", interval, dur)
+	}
+}
+
+// Ensure that Peek and Schedule apply the first delay, so that they
+// match the next call to Duration.
+func TestFirstDelayPeek(t *testing.T) {
+	b := NewWithoutJitter(max, 4*interval)
+	b.SetFirstDelay(interval)
+
+	if dur := b.Peek(); dur != interval {
+		t.Fatalf("want peek=%s, have %s", interval, dur)
+	}
+
+	schedule := b.Schedule(2)
+	if schedule[0] != interval || schedule[1] != 8*interval {
+		t.Fatalf("want schedule=[%s %s], have %v", interval, 8*interval, schedule)
+	}
+
+	if dur := b.Duration(); dur != interval {
+		t.Fatalf("want duration=%s, have %s", interval, dur)
+	}
+
+	if dur := b.Peek(); dur != 8*interval {
+		t.Fatalf("want peek=%s after the first try, have %s", 8*interval, dur)
+	}
+}
+
+// Ensure that a monotonic backoff doesn't return a shorter duration
+// after being reconfigured, until it is reset.
+func TestSetMonotonic(t *testing.T) {
+	b := NewWithoutJitter(max, 4*interval)
+	b.SetMonotonic()
+
+	if dur := b.Duration(); dur != 4*interval {
+		t.Fatalf("want duration=%s, have %s", 4*interval, dur)
+	}
+
+	b.SetInterval(interval)
+	if dur := b.Peek(); dur != 4*interval {
+		t.Fatalf("want peek=%s, have %s", 4*interval, dur)
+	}
+
+	if dur := b.Duration(); dur != 4*interval {
+		t.Fatalf("want duration=%s, have %s", 4*interval, dur)
+	}
+
+	if dur := b.Duration(); dur != 4*interval {
+		t.Fatalf("want duration=%s, have %s", 4*interval, dur)
+	}
+
+	if dur := b.Duration(); dur != 8*interval {
+		t.Fatalf("want duration=%s, have %s", 8*interval, dur)
+	}
+
+	b.Reset()
+	if dur := b.Duration(); dur != interval {
+		t.Fatalf("want duration=%s after Reset, have %s", interval, dur)
+	}
+}
+
+// Ensure that Max returns the longest duration, and advances all of the
+// backoffs.
+func TestMax(t *testing.T) {
+	b1 := NewWithoutJitter(max, interval)
+	b2 := NewWithoutJitter(max, 3*interval)
+
+	if dur := Max(b1, b2); dur != 3*interval {
+		t.Fatalf("want duration=%s, have %s", 3*interval, dur)
+	}
+
+	if b1.Tries() != 1 || b2.Tries() != 1 {
+		t.Fatalf("expected tries=1, have tries=%d and %d", b1.Tries(), b2.Tries())
+	}
+
+	if dur := Max(); dur != 0 {
+		t.Fatalf("want duration=0, have %s", dur)
+	}
+}
+
+// Ensure that Compute matches the durations of a Backoff, and that
+// jittered durations are within range.
+func TestCompute(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	for attempt := uint64(0); attempt < 70; attempt++ {
+		want := b.Duration()
+		if have := Compute(attempt, interval, max, false); have != want {
+			t.Fatalf("want duration=%s, have %s at attempt=%d", want, have, attempt)
+		}
+
+		if have := Compute(attempt, interval, max, true); have < 0 || have >= want {
+			t.Fatalf("expected 0 <= duration < %s, have %s at attempt=%d", want, have, attempt)
+		}
+	}
+
+	if have := Compute(0, 0, 0, false); have != DefaultInterval {
+		t.Fatalf("want duration=%s, have %s", DefaultInterval, have)
+	}
+
+	if have := Compute(math.MaxUint64, 0, 0, false); have != DefaultMaxDuration {
+		t.Fatalf("want duration=%s, have %s", DefaultMaxDuration, have)
+	}
+
+	if computeRand.rng == nil {
+		t.Fatal("expected Compute to use its own source of randomness")
+	}
+}
+
+// Ensure that Compute can be called concurrently with jitter; this is
+// mostly useful when run with the race detector.
+func TestComputeConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := uint64(0); attempt < 100; attempt++ {
+				if have := Compute(attempt, interval, max, true); have < 0 || have >= max {
+					t.Errorf("expected 0 <= duration < %s, have %s", max, have)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Ensure that tries incremenets as expected.
+func TestTries(t *testing.T) {
+	b := NewWithoutJitter(5, 1)
+
+	for i := uint64(0); i < 3; i++ {
+		if b.n != i {
+			t.Fatalf("want tries=%d, have tries=%d", i, b.n)
+		}
+
+		pow := 1 << i
+		expected := time.Duration(pow)
+		dur := b.Duration()
+		if dur != expected {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", expected, dur, i)
+		}
+	}
+
+	for i := uint(3); i < 5; i++ {
+		dur := b.Duration()
+		if dur != 5 {
+			t.Fatalf("want duration=5, have %d at i=%d", dur, i)
+		}
+	}
+}
+
+// Ensure that CeilingAttempt returns the first exponent whose duration
+// reaches the max duration.
+func TestCeilingAttempt(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *Backoff
+		want uint64
+	}{
+		{"exponential", NewWithoutJitter(10*time.Second, 20*time.Millisecond), 9},
+		{"exact", NewWithoutJitter(8*time.Second, time.Second), 3},
+		{"interval at max", NewWithoutJitter(time.Second, time.Second), 0},
+		{"factor", NewWithOptions(WithInterval(time.Second), WithMaxDuration(5*time.Second), WithFactor(3)), 2},
+		{"linear", NewWithoutJitter(5*time.Second, time.Second), 4},
+		{"constant", NewWithoutJitter(time.Hour, time.Second), math.MaxUint64},
+	}
+	tests[4].b.SetLinear()
+	tests[5].b.SetConstant()
+
+	for _, tc := range tests {
+		if have := tc.b.CeilingAttempt(); have != tc.want {
+			t.Fatalf("expected ceiling attempt %d for %s, have %d", tc.want, tc.name, have)
+		}
+
+		if tc.b.Tries() != 0 {
+			t.Fatalf("expected tries=0 for %s, have tries=%d", tc.name, tc.b.Tries())
+		}
+	}
+
+	b := NewWithoutJitter(10*time.Second, 20*time.Millisecond)
+	b.SetMaxExponent(5)
+	if have := b.CeilingAttempt(); have != math.MaxUint64 {
+		t.Fatalf("expected no ceiling attempt with a max exponent, have %d", have)
+	}
+}
+
+// Ensure that SetTries sets up the backoff as if that many tries had
+// been made.
+func TestSetTries(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetTries(3)
+
+	if b.Tries() != 3 || b.Exponent() != 3 {
+		t.Fatalf("expected tries=3, exponent=3, have tries=%d, exponent=%d", b.Tries(), b.Exponent())
+	}
+
+	if dur := b.Duration(); dur != 8*interval {
+		t.Fatalf("want duration=%s, have %s", 8*interval, dur)
+	}
+
+	b.SetTries(math.MaxUint64)
+	if dur := b.Duration(); dur != max {
+		t.Fatalf("want duration=%s, have %s", max, dur)
+	}
+
+	if b.Tries() != math.MaxUint64 {
+		t.Fatalf("expected tries to saturate, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that durations grow by the configured factor.
+func TestFactor(t *testing.T) {
+	b := NewWithoutJitter(100, 4)
+	b.SetFactor(1.5)
+
+	expected := []time.Duration{4, 6, 9, 13, 20, 30, 45, 68, 100, 100}
+	for i, want := range expected {
+		dur := b.Duration()
+		if dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", want, dur, i)
+		}
+	}
+}
+
+// Ensure that a factor of 2 matches integer doubling exactly, whether
+// it is set explicitly or left as the default.
+func TestFactorTwoExact(t *testing.T) {
+	const interval = 123456789*time.Nanosecond + 1
+
+	// legacy is the original computation of the nth duration.
+	legacy := func(n uint64) time.Duration {
+		pow := time.Duration(math.MaxInt64)
+		if n < 63 {
+			pow = 1 << n
+		}
+
+		d := interval * pow
+		if d/pow != interval {
+			d = math.MaxInt64
+		}
+		return d
+	}
+
+	explicit := NewWithoutJitter(math.MaxInt64, interval)
+	explicit.SetFactor(2)
+	implicit := NewWithoutJitter(math.MaxInt64, interval)
+	for n := uint64(0); n < 70; n++ {
+		want := legacy(n)
+		if dur := explicit.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at n=%d", want, dur, n)
+		}
+
+		if dur := implicit.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at n=%d with the default factor", want, dur, n)
+		}
+	}
+}
+
+// Ensure that a non-integer factor saturates at the max duration
+// rather than overflowing.
+func TestFactorSaturation(t *testing.T) {
+	b := NewWithoutJitter(time.Duration(math.MaxInt64), time.Hour)
+	b.SetFactor(3.5)
+	b.n = math.MaxUint64
+
+	dur := b.Duration()
+	if dur != time.Duration(math.MaxInt64) {
+		t.Fatalf("want duration=%d, have duration=%d", time.Duration(math.MaxInt64), dur)
+	}
+}
+
+// SetFactor should reject factors that don't grow the duration.
+func TestFactorInvalid(t *testing.T) {
+	for _, factor := range []float64{-1, 0, 1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected SetFactor(%v) to panic", factor)
+				}
+			}()
+			new(Backoff).SetFactor(factor)
+		}()
+	}
+}
+
+// Ensure that no duration is shorter than the minimum, regardless of
+// jitter.
+func TestMin(t *testing.T) {
+	const iter = 1000
+
+	b := New(time.Second, time.Millisecond)
+	b.SetMin(5 * time.Millisecond)
+
+	for i := 0; i < iter; i++ {
+		dur := b.Duration()
+		if dur < 5*time.Millisecond || dur > time.Second {
+			t.Fatalf("want duration between %s and %s, have %s at i=%d", 5*time.Millisecond, time.Second, dur, i)
+		}
+	}
+
+	b = NewWithoutJitter(time.Second, time.Millisecond)
+	b.SetMin(5 * time.Millisecond)
+	for _, want := range []time.Duration{5, 5, 5, 8, 16} {
+		want *= time.Millisecond
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%s, have %s", want, dur)
+		}
+	}
+}
+
+// SetMin should reject a minimum greater than the max duration.
+func TestMinInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected SetMin to panic")
+		}
+	}()
+
+	b := New(time.Second, time.Millisecond)
+	b.SetMin(2 * time.Second)
+}
+
+// Ensure that the exponent can be capped below the max duration.
+func TestMaxExponent(t *testing.T) {
+	b := NewWithoutJitter(1000, 1)
+	b.SetMaxExponent(3)
+
+	expected := []time.Duration{1, 2, 4, 8, 8, 8}
+	for i, want := range expected {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", want, dur, i)
+		}
+	}
+
+	if b.Tries() != uint64(len(expected)) {
+		t.Fatalf("want tries=%d, have tries=%d", len(expected), b.Tries())
+	}
+}
+
+// Ensure that Peek returns the next duration without incrementing the
+// attempt counter.
+func TestPeek(t *testing.T) {
+	b := NewWithoutJitter(5, 1)
+
+	for i := uint64(0); i < 5; i++ {
+		peeked := b.Peek()
+		if b.n != i {
+			t.Fatalf("want tries=%d after peek, have tries=%d", i, b.n)
+		}
+
+		dur := b.Duration()
+		if peeked != dur {
+			t.Fatalf("want peeked duration=%d to match duration=%d at i=%d", peeked, dur, i)
+		}
+	}
+}
+
+// With jitter enabled, Peek should return the upper bound of the next
+// duration.
+func TestPeekJitter(t *testing.T) {
+	b := New(1000, 1)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	if peeked := b.Peek(); peeked != 8 {
+		t.Fatalf("want peeked duration=8, have %d", peeked)
+	}
+}
+
+// Ensure that Next returns the incremented attempt counter along with
+// the duration.
+func TestNext(t *testing.T) {
+	b := NewWithoutJitter(5, 1)
+
+	for i := uint64(1); i <= 3; i++ {
+		attempt, dur := b.Next()
+		if attempt != i {
+			t.Fatalf("want attempt=%d, have attempt=%d", i, attempt)
+		}
+
+		if expected := time.Duration(1 << (i - 1)); dur != expected {
+			t.Fatalf("want duration=%d, have duration=%d at attempt=%d", expected, dur, attempt)
+		}
+
+		if b.Tries() != attempt {
+			t.Fatalf("want tries=%d, have tries=%d", attempt, b.Tries())
+		}
+	}
+}
+
+// Ensure that a backoff is exhausted once it reaches its max tries,
+// and that a reset clears it.
+func TestExhausted(t *testing.T) {
+	b := NewWithoutJitter(5, 1)
+	if b.Exhausted() {
+		t.Fatal("expected backoff without max tries to not be exhausted")
+	}
+
+	b.SetMaxTries(3)
+	for i := 0; i < 3; i++ {
+		if b.Exhausted() {
+			t.Fatalf("expected backoff to not be exhausted at i=%d", i)
+		}
+		_ = b.Duration()
+	}
+
+	if !b.Exhausted() {
+		t.Fatal("expected backoff to be exhausted after 3 tries")
+	}
+
+	b.Reset()
+	if b.Exhausted() {
+		t.Fatal("expected backoff to not be exhausted after reset")
+	}
+}
+
+// Ensure that the breaker opens once the backoff reaches its
+// threshold, and that a reset closes it.
+func TestBreaker(t *testing.T) {
+	b := NewWithoutJitter(5, 1)
+	_ = b.Duration()
+	if b.Open() {
+		t.Fatal("expected backoff without a breaker to not be open")
+	}
+
+	b.SetBreaker(3)
+	for i := 1; i < 3; i++ {
+		if b.Open() {
+			t.Fatalf("expected breaker to be closed at i=%d", i)
+		}
+		_ = b.Duration()
+	}
+
+	if !b.Open() {
+		t.Fatal("expected breaker to be open after 3 tries")
+	}
+
+	b.Reset()
+	if b.Open() {
+		t.Fatal("expected breaker to be closed after reset")
+	}
+}
+
+// Ensure that the budget is exceeded once the durations handed out
+// add up to it, and that a reset clears it.
+func TestBudget(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+	b.SetBudget(10)
+
+	// 1 + 2 + 4 = 7
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+		if b.BudgetExceeded() {
+			t.Fatalf("expected budget to not be exceeded after %d tries", i+1)
+		}
+	}
+
+	// 7 + 8 = 15
+	_ = b.Duration()
+	if !b.BudgetExceeded() {
+		t.Fatal("expected budget to be exceeded after 4 tries")
+	}
+
+	b.Reset()
+	if b.BudgetExceeded() {
+		t.Fatal("expected budget to not be exceeded after reset")
+	}
+}
+
+func TestString(t *testing.T) {
+	b := New(0, 0)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	expected := "Backoff{interval=5m0s, max=6h0m0s, tries=3, jitter=true}"
+	if s := fmt.Sprintf("%s", b); s != expected {
+		t.Fatalf("expected %q, have %q", expected, s)
+	}
+
+	expected = "Backoff{interval=1s, max=1m0s, tries=0, jitter=false}"
+	if s := NewWithoutJitter(time.Minute, time.Second).String(); s != expected {
+		t.Fatalf("expected %q, have %q", expected, s)
+	}
+}
+
+// Ensure that a clone has the same configuration as the original, but
+// its own state.
+func TestClone(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.SetFactor(3)
+	b.SetMaxTries(5)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	c := b.Clone()
+	if c.config != b.config {
+		t.Fatalf("expected clone to have config %+v, have %+v", b.config, c.config)
+	}
+
+	if c.n != 0 {
+		t.Fatalf("expected clone to have tries=0, have tries=%d", c.n)
+	}
+
+	if c.rng == b.rng {
+		t.Fatal("expected clone to have its own PRNG")
+	}
+
+	if dur := c.Duration(); dur != time.Second {
+		t.Fatalf("expected duration=%s, have %s", time.Second, dur)
+	}
+
+	if b.n != 3 {
+		t.Fatalf("expected original to have tries=3, have tries=%d", b.n)
+	}
+}
+
+// Ensure that a copy continues from the same point as the original,
+// and that the two are independent afterwards.
+func TestCopy(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.SetMaxTries(5)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	c := b.Copy()
+	if c.config != b.config {
+		t.Fatalf("expected copy to have config %+v, have %+v", b.config, c.config)
+	}
+
+	if c.Tries() != 3 || c.LastDuration() != 4*time.Second || c.TotalElapsed() != 7*time.Second {
+		t.Fatalf("expected copy to have tries=3, last=4s, total=7s, have tries=%d, last=%s, total=%s",
+			c.Tries(), c.LastDuration(), c.TotalElapsed())
+	}
+
+	if c.rng == b.rng {
+		t.Fatal("expected copy to have its own PRNG")
+	}
+
+	if dur := c.Duration(); dur != 8*time.Second {
+		t.Fatalf("expected duration=%s, have %s", 8*time.Second, dur)
+	}
+
+	if b.Tries() != 3 {
+		t.Fatalf("expected original to have tries=3, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that DurationWithHint returns the larger of the hint and the
+// computed duration, capped at the max duration.
+func TestDurationWithHint(t *testing.T) {
+	b := NewWithoutJitter(100, 4)
+
+	tests := []struct {
+		hint, expected time.Duration
+	}{
+		{0, 4},
+		{2, 8},
+		{50, 50},
+		{1000, 100},
+	}
+
+	for i, tt := range tests {
+		if dur := b.DurationWithHint(tt.hint); dur != tt.expected {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", tt.expected, dur, i)
+		}
+	}
+
+	if b.n != uint64(len(tests)) {
+		t.Fatalf("want tries=%d, have tries=%d", len(tests), b.n)
+	}
+}
+
+// Ensure that DurationWith uses the given interval for one call only.
+func TestDurationWith(t *testing.T) {
+	b := NewWithoutJitter(1000, 4)
+
+	tests := []struct {
+		interval, expected time.Duration
+	}{
+		{0, 4},
+		{10, 20},
+		{0, 16},
+		{1, 8},
+	}
+
+	for i, tt := range tests {
+		if dur := b.DurationWith(tt.interval); dur != tt.expected {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", tt.expected, dur, i)
+		}
+	}
+
+	if b.interval != 4 {
+		t.Fatalf("expected interval=4 to be unchanged, have %d", b.interval)
+	}
+
+	if b.Tries() != uint64(len(tests)) {
+		t.Fatalf("want tries=%d, have tries=%d", len(tests), b.Tries())
+	}
+}
+
+// Ensure that DurationScaled scales the computed duration, clamped to
+// the max and min durations, without changing the attempt counter.
+func TestDurationScaled(t *testing.T) {
+	b := NewWithoutJitter(100, 4)
+	b.SetMin(2)
+
+	tests := []struct {
+		factor   float64
+		expected time.Duration
+	}{
+		{1, 4},
+		{0.5, 4},
+		{0, 2},
+		{2, 64},
+		{1e300, 100},
+	}
+
+	for i, tt := range tests {
+		if dur := b.DurationScaled(tt.factor); dur != tt.expected {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", tt.expected, dur, i)
+		}
+	}
+
+	if b.Tries() != uint64(len(tests)) {
+		t.Fatalf("want tries=%d, have tries=%d", len(tests), b.Tries())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected DurationScaled to panic with a negative factor")
+		}
+	}()
+	b.DurationScaled(-1)
+}
+
+// Ensure that changing the interval and max duration takes effect
+// immediately, including when the max is lowered below the current
+// duration.
+func TestSetIntervalMaxDuration(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+	for i := 0; i < 5; i++ {
+		_ = b.Duration()
+	}
+
+	b.SetMaxDuration(10)
+	if dur := b.Duration(); dur != 10 {
+		t.Fatalf("expected duration=10, have duration=%d", dur)
+	}
+
+	b.Reset()
+	b.SetInterval(3)
+	if dur := b.Duration(); dur != 3 {
+		t.Fatalf("expected duration=3, have duration=%d", dur)
+	}
+
+	b.SetInterval(0)
+	b.SetMaxDuration(0)
+	if b.interval != DefaultInterval || b.maxDuration != DefaultMaxDuration {
+		t.Fatalf("expected defaults, have interval=%s, max=%s", b.interval, b.maxDuration)
+	}
+}
+
+// SetMaxDuration should refuse to go below the minimum duration.
+func TestSetMaxDurationBelowMin(t *testing.T) {
+	b := New(time.Minute, time.Second)
+	b.SetMin(10 * time.Second)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected SetMaxDuration to panic")
+		}
+
+		if b.maxDuration != time.Minute {
+			t.Fatalf("expected max duration to be unchanged, have %s", b.maxDuration)
+		}
+	}()
+	b.SetMaxDuration(time.Second)
+}
+
+// Ensure that the configuration can be changed while the backoff is in
+// use; this is mostly useful when run with the race detector.
+func TestSetConcurrent(t *testing.T) {
+	b := New(time.Second, time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if dur := b.Duration(); dur > time.Second {
+				t.Errorf("expected duration <= %s, have %s", time.Second, dur)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		b.SetInterval(time.Duration(i+1) * time.Microsecond)
+		b.SetMaxDuration(time.Duration(i+1) * time.Millisecond)
+	}
+	<-done
+}
+
+// Ensure that Remaining counts down to zero from the max tries.
+func TestRemaining(t *testing.T) {
+	b := NewWithoutJitter(5, 1)
+	if r := b.Remaining(); r != math.MaxUint64 {
+		t.Fatalf("expected remaining=%d without max tries, have %d", uint64(math.MaxUint64), r)
+	}
+
+	b.SetMaxTries(3)
+	for i := uint64(0); i < 5; i++ {
+		expected := uint64(0)
+		if i < 3 {
+			expected = 3 - i
+		}
+
+		if r := b.Remaining(); r != expected {
+			t.Fatalf("expected remaining=%d, have %d at i=%d", expected, r, i)
+		}
+		_ = b.Duration()
+	}
+}
+
+// Ensure that AtCeiling reports when the backoff reaches the max
+// duration.
+func TestAtCeiling(t *testing.T) {
+	b := New(8, 1)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+		if b.AtCeiling() {
+			t.Fatalf("expected backoff to not be at ceiling after %d tries", i+1)
+		}
+	}
+
+	_ = b.Duration()
+	if !b.AtCeiling() {
+		t.Fatal("expected backoff to be at ceiling after 4 tries")
+	}
+
+	b.Reset()
+	if b.AtCeiling() {
+		t.Fatal("expected backoff to not be at ceiling after reset")
+	}
+}
+
+// Ensure that the OnRetry callback is called with each attempt and
+// duration.
+func TestOnRetry(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+
+	var attempts []uint64
+	var durations []time.Duration
+	b.SetOnRetry(func(attempt uint64, d time.Duration) {
+		attempts = append(attempts, attempt)
+		durations = append(durations, d)
+	})
+
+	_ = b.Duration()
+	_, _ = b.Next()
+	_ = b.DurationWithHint(10)
+
+	expectedAttempts := []uint64{1, 2, 3}
+	expectedDurations := []time.Duration{1, 2, 10}
+	for i := range expectedAttempts {
+		if attempts[i] != expectedAttempts[i] || durations[i] != expectedDurations[i] {
+			t.Fatalf("expected callback with attempt=%d, duration=%d, have attempt=%d, duration=%d",
+				expectedAttempts[i], expectedDurations[i], attempts[i], durations[i])
+		}
+	}
+
+	b.SetOnRetry(nil)
+	_ = b.Duration()
+	if len(attempts) != 3 {
+		t.Fatalf("expected no callback after removing it, have %d calls", len(attempts))
+	}
+}
+
+// Ensure that Snapshot reports the state of the backoff.
+func TestSnapshot(t *testing.T) {
+	b := NewWithoutJitter(8, 1)
+	if stats := b.Snapshot(); stats != (Stats{}) {
+		t.Fatalf("expected empty stats, have %+v", stats)
+	}
+
+	for i := 0; i < 5; i++ {
+		_ = b.Duration()
+	}
+	b.Retreat()
+
+	expected := Stats{
+		Tries:           5,
+		CurrentExponent: 4,
+		LastDuration:    8,
+		AtCeiling:       true,
+	}
+	if stats := b.Snapshot(); stats != expected {
+		t.Fatalf("expected stats %+v, have %+v", expected, stats)
+	}
+}
+
+// Backoffs with the same seed should produce the same durations.
+func TestSeed(t *testing.T) {
+	b1 := NewWithSeed(time.Hour, time.Millisecond, 42)
+	b2 := NewWithOptions(WithMaxDuration(time.Hour), WithInterval(time.Millisecond), WithSeed(42))
 
-	for i := uint64(0); i < 3; i++ {
-		if b.n != i {
-			t.Fatalf("want tries=%d, have tries=%d", i, b.n)
+	for i := 0; i < 20; i++ {
+		d1, d2 := b1.Duration(), b2.Duration()
+		if d1 != d2 {
+			t.Fatalf("want matching durations, have %s and %s at i=%d", d1, d2, i)
 		}
+	}
+}
 
-		pow := 1 << i
-		expected := time.Duration(pow)
-		dur := b.Duration()
-		if dur != expected {
-			t.Fatalf("want duration=%d, have duration=%d at i=%d", expected, dur, i)
+// Ensure that Schedule returns the upcoming durations without
+// consuming them.
+func TestSchedule(t *testing.T) {
+	b := New(10, 1)
+	_ = b.Duration()
+
+	expected := []time.Duration{2, 4, 8, 10, 10}
+	schedule := b.Schedule(len(expected))
+	if len(schedule) != len(expected) {
+		t.Fatalf("want %d durations, have %d", len(expected), len(schedule))
+	}
+
+	for i, want := range expected {
+		if schedule[i] != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", want, schedule[i], i)
 		}
 	}
 
-	for i := uint(3); i < 5; i++ {
+	if b.n != 1 {
+		t.Fatalf("want tries=1 after schedule, have tries=%d", b.n)
+	}
+
+	if schedule := b.Schedule(0); schedule != nil {
+		t.Fatalf("want an empty schedule, have %v", schedule)
+	}
+}
+
+// Ensure that TotalElapsed sums the durations returned, and is cleared
+// by a reset.
+func TestTotalElapsed(t *testing.T) {
+	b := New(time.Second, time.Millisecond)
+
+	var sum time.Duration
+	for i := 0; i < 10; i++ {
+		sum += b.Duration()
+	}
+
+	if total := b.TotalElapsed(); total != sum {
+		t.Fatalf("expected total=%s, have %s", sum, total)
+	}
+
+	b.Reset()
+	if total := b.TotalElapsed(); total != 0 {
+		t.Fatalf("expected total=0 after reset, have %s", total)
+	}
+}
+
+// Ensure that DurationOrAbort stops once the backoff is exhausted or
+// over budget, without incrementing the attempt counter.
+func TestDurationOrAbort(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+	b.SetMaxTries(2)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := b.DurationOrAbort(); !ok {
+			t.Fatalf("expected a duration at i=%d", i)
+		}
+	}
+
+	if dur, ok := b.DurationOrAbort(); ok || dur != 0 {
+		t.Fatalf("expected the backoff to abort, have duration=%d, ok=%t", dur, ok)
+	}
+
+	if b.Tries() != 2 {
+		t.Fatalf("expected tries=2, have tries=%d", b.Tries())
+	}
+
+	b = NewWithoutJitter(100, 1)
+	b.SetBudget(3)
+	for _, want := range []time.Duration{1, 2} {
+		if dur, ok := b.DurationOrAbort(); !ok || dur != want {
+			t.Fatalf("expected duration=%d, have duration=%d, ok=%t", want, dur, ok)
+		}
+	}
+
+	if _, ok := b.DurationOrAbort(); ok {
+		t.Fatal("expected the backoff to abort once over budget")
+	}
+}
+
+// Ensure that LastDuration returns the last duration, and is cleared by
+// a reset.
+func TestLastDuration(t *testing.T) {
+	b := New(time.Second, time.Millisecond)
+	if last := b.LastDuration(); last != 0 {
+		t.Fatalf("expected last duration=0, have %s", last)
+	}
+
+	for i := 0; i < 5; i++ {
 		dur := b.Duration()
-		if dur != 5 {
-			t.Fatalf("want duration=5, have %d at i=%d", dur, i)
+		if last := b.LastDuration(); last != dur {
+			t.Fatalf("expected last duration=%s, have %s", dur, last)
 		}
 	}
+
+	b.Reset()
+	if last := b.LastDuration(); last != 0 {
+		t.Fatalf("expected last duration=0 after reset, have %s", last)
+	}
 }
 
 // Ensure that a call to Reset will actually reset the Backoff.
@@ -87,6 +1072,258 @@ func TestReset(t *testing.T) {
 	}
 }
 
+// Ensure that NextTime returns the current time plus the duration,
+// and increments the attempt counter.
+func TestNextTime(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	b := NewWithoutJitter(max, interval)
+	b.setClock(clock.Now)
+
+	if at := b.NextTime(); !at.Equal(clock.now.Add(interval)) {
+		t.Fatalf("want next time=%s, have %s", clock.now.Add(interval), at)
+	}
+
+	clock.Advance(time.Second)
+	if at := b.NextTime(); !at.Equal(clock.now.Add(2 * interval)) {
+		t.Fatalf("want next time=%s, have %s", clock.now.Add(2*interval), at)
+	}
+
+	if b.Tries() != 2 {
+		t.Fatalf("want tries=2, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that the first duration after a recent success is shortened
+// with quick recovery, and only then.
+func TestQuickRecovery(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewWithoutJitter(time.Hour, 10*time.Second)
+	b.setClock(clock.Now)
+	b.SetQuickRecovery()
+
+	// Without a success, the first duration isn't shortened.
+	if dur := b.Duration(); dur != 10*time.Second {
+		t.Fatalf("want duration=%s, have %s", 10*time.Second, dur)
+	}
+
+	b.MarkSuccess()
+	if b.Tries() != 0 {
+		t.Fatalf("expected MarkSuccess to reset tries, have tries=%d", b.Tries())
+	}
+
+	clock.Advance(2 * time.Second)
+	if dur := b.Duration(); dur != 2*time.Second {
+		t.Fatalf("want duration=%s, have %s", 2*time.Second, dur)
+	}
+
+	if dur := b.Duration(); dur != 20*time.Second {
+		t.Fatalf("want duration=%s, have %s", 20*time.Second, dur)
+	}
+
+	b.MarkSuccess()
+	clock.Advance(10 * time.Second)
+	if dur := b.Duration(); dur != 10*time.Second {
+		t.Fatalf("want duration=%s once the interval has passed, have %s", 10*time.Second, dur)
+	}
+}
+
+// Ensure that ResetReport reports whether there were any tries to
+// reset.
+func TestResetReport(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	if b.ResetReport() {
+		t.Fatal("expected ResetReport to report no change for a new backoff")
+	}
+
+	b.Duration()
+	b.Duration()
+	if !b.ResetReport() {
+		t.Fatal("expected ResetReport to report a change after two tries")
+	}
+
+	if b.Tries() != 0 {
+		t.Fatalf("want tries=0, have tries=%d", b.Tries())
+	}
+
+	if b.ResetReport() {
+		t.Fatal("expected a second ResetReport to report no change")
+	}
+}
+
+// Ensure that ResetIf only resets the backoff if fn returns true.
+func TestResetIf(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	atLeast := func(n uint64) func(uint64) bool {
+		return func(tries uint64) bool { return tries >= n }
+	}
+
+	if b.ResetIf(atLeast(4)) || b.Tries() != 3 {
+		t.Fatalf("expected no reset with tries=3, have tries=%d", b.Tries())
+	}
+
+	if !b.ResetIf(atLeast(3)) || b.Tries() != 0 {
+		t.Fatalf("expected a reset with tries=3, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that concurrent calls to Reset and Duration are serialised,
+// so that the number of tries never exceeds the number of calls to
+// Duration since the reset that was last observed.
+func TestConcurrentReset(t *testing.T) {
+	const iter = 1000
+
+	b := NewWithoutJitter(max, interval)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iter; i++ {
+			b.Reset()
+		}
+	}()
+
+	for i := 0; i < iter; i++ {
+		_ = b.Duration()
+		b.ResetIf(func(tries uint64) bool {
+			if tries > 1 {
+				t.Errorf("expected tries <= 1, have tries=%d", tries)
+			}
+			return true
+		})
+	}
+	wg.Wait()
+}
+
+// Ensure that ResetWithJitter resets the number of tries, and sets the
+// exponent to a value between 0 and 2.
+func TestResetWithJitter(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetRandSource(fixedSource{})
+	for i := 0; i < 5; i++ {
+		_ = b.Duration()
+	}
+
+	b.ResetWithJitter()
+	if b.Tries() != 0 || b.Exponent() != 2 {
+		t.Fatalf("expected tries=0, exponent=2, have tries=%d, exponent=%d", b.Tries(), b.Exponent())
+	}
+
+	if dur := b.Duration(); dur != 4*interval {
+		t.Fatalf("want duration=%s, have %s", 4*interval, dur)
+	}
+
+	b.SetRandSource(nil)
+	for i := 0; i < 100; i++ {
+		b.ResetWithJitter()
+		if n := b.Exponent(); n > 2 {
+			t.Fatalf("expected exponent <= 2, have %d", n)
+		}
+	}
+}
+
+// Ensure that Backoffs can be used from multiple goroutines; this is
+// mostly useful when run with the race detector.
+func TestConcurrent(t *testing.T) {
+	const workers = 8
+	const iter = 1000
+
+	var wg sync.WaitGroup
+	shared := New(time.Second, time.Millisecond)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			b := New(time.Second, time.Millisecond)
+			for j := 0; j < iter; j++ {
+				_ = b.Duration()
+				_ = shared.Duration()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if shared.n != workers*iter {
+		t.Fatalf("expected tries=%d, have tries=%d", workers*iter, shared.n)
+	}
+}
+
+// Ensure that Retreat decrements the exponent, but not the number of
+// tries.
+func TestRetreat(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+	b.Retreat()
+	if b.n != 0 {
+		t.Fatalf("expected exponent=0, have exponent=%d", b.n)
+	}
+
+	for i := 0; i < 4; i++ {
+		_ = b.Duration()
+	}
+
+	b.Retreat()
+	if dur := b.Duration(); dur != 8 {
+		t.Fatalf("expected duration=8, have duration=%d", dur)
+	}
+
+	if b.Tries() != 5 {
+		t.Fatalf("expected tries=5, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that backoffs without jitter keep the counters consistent
+// under concurrent use.
+func TestConcurrentNoJitter(t *testing.T) {
+	const workers = 8
+	const iter = 1000
+
+	b := NewWithoutJitter(time.Second, time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iter; j++ {
+				if dur := b.Duration(); dur > time.Second {
+					t.Errorf("expected duration <= %s, have %s", time.Second, dur)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := b.Snapshot()
+	if stats.Tries != workers*iter || stats.CurrentExponent != workers*iter {
+		t.Fatalf("expected tries=%d, have %+v", workers*iter, stats)
+	}
+
+	if !stats.AtCeiling || stats.LastDuration != time.Second {
+		t.Fatalf("expected backoff to be at ceiling, have %+v", stats)
+	}
+}
+
+// Ensure that Exponent differs from Tries after a Retreat.
+func TestExponent(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	if b.Exponent() != 3 || b.Tries() != 3 {
+		t.Fatalf("expected exponent=3, tries=3, have exponent=%d, tries=%d", b.Exponent(), b.Tries())
+	}
+
+	b.Retreat()
+	if b.Exponent() != 2 || b.Tries() != 3 {
+		t.Fatalf("expected exponent=2, tries=3, have exponent=%d, tries=%d", b.Exponent(), b.Tries())
+	}
+}
+
 const decay = 5 * time.Millisecond
 const max = 10 * time.Millisecond
 const interval = time.Millisecond
@@ -144,6 +1381,227 @@ func TestDecaySaturation(t *testing.T) {
 	}
 }
 
+// Ensure that Wait sleeps for the backoff duration and advances the
+// attempt counter.
+func TestWait(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	start := time.Now()
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatalf("expected nil error, have %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Fatalf("expected to wait at least %s, waited %s", interval, elapsed)
+	}
+
+	if b.n != 1 {
+		t.Fatalf("expected tries=1, have tries=%d", b.n)
+	}
+}
+
+// Ensure that Wait returns early when the context is cancelled.
+func TestWaitCancelled(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := b.Wait(ctx)
+	if err != context.Canceled {
+		t.Fatalf("expected %v, have %v", context.Canceled, err)
+	}
+}
+
+// Ensure that WaitReport returns how long it waited, both when the
+// duration elapses and when the context is done first.
+func TestWaitReport(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	waited, err := b.WaitReport(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, have %v", err)
+	}
+
+	if waited < interval {
+		t.Fatalf("expected to wait at least %s, waited %s", interval, waited)
+	}
+
+	b = NewWithoutJitter(time.Hour, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	waited, err = b.WaitReport(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	if waited >= b.LastDuration() {
+		t.Fatalf("expected to wait less than %s, waited %s", b.LastDuration(), waited)
+	}
+}
+
+// fakeClock is a manually-advanced clock for tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// Ensure that decay resets the try counter based on the backoff's
+// clock, without waiting in real time.
+func TestDecayClock(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewWithoutJitter(time.Hour, time.Second)
+	b.SetDecay(10 * time.Second)
+	b.setClock(clock.Now)
+
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	// The last duration was 4s, so a gap of 14s won't reset.
+	clock.Advance(14 * time.Second)
+	if dur := b.Duration(); dur != 8*time.Second {
+		t.Fatalf("expected duration=%s, have %s", 8*time.Second, dur)
+	}
+
+	// The last duration was 8s, so a gap of more than 18s resets.
+	clock.Advance(18*time.Second + 1)
+	if dur := b.Duration(); dur != time.Second {
+		t.Fatalf("expected duration=%s, have %s", time.Second, dur)
+	}
+}
+
+// Ensure that decay still resets the try counter after Retreat has
+// brought the attempt counter back to zero.
+func TestDecayRetreat(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewWithoutJitter(time.Hour, time.Second)
+	b.SetDecay(time.Second)
+	b.setClock(clock.Now)
+
+	_ = b.Duration()
+	_ = b.Duration()
+	b.Retreat()
+	b.Retreat()
+
+	// The last duration was 2s, so a gap of 10s resets.
+	clock.Advance(10 * time.Second)
+	if dur := b.Duration(); dur != time.Second {
+		t.Fatalf("expected duration=%s, have %s", time.Second, dur)
+	}
+
+	if b.Tries() != 1 {
+		t.Fatalf("want tries=1, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that a clock jumping backwards, as a wall clock can, doesn't
+// reset the try counter, and that the default clock records the time of
+// each try with a monotonic clock reading, so that wall clock jumps
+// don't affect decay.
+func TestDecayClockJump(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(3600, 0)}
+	b := NewWithoutJitter(time.Hour, time.Second)
+	b.SetDecay(10 * time.Second)
+	b.setClock(clock.Now)
+
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	clock.Advance(-time.Hour)
+	if dur := b.Duration(); dur != 8*time.Second {
+		t.Fatalf("expected duration=%s, have %s", 8*time.Second, dur)
+	}
+
+	b = NewWithoutJitter(time.Hour, time.Second)
+	b.SetDecay(10 * time.Second)
+	_ = b.Duration()
+	if s := b.lastTry.String(); !strings.Contains(s, "m=") {
+		t.Fatalf("expected the last try to have a monotonic clock reading, have %s", s)
+	}
+}
+
+// Ensure that After fires once the backoff duration has elapsed, and
+// increments the attempt counter once per call.
+func TestAfter(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	for i := uint64(1); i <= 2; i++ {
+		select {
+		case <-b.After():
+		case <-time.After(time.Second):
+			t.Fatal("expected After to fire")
+		}
+
+		if b.n != i {
+			t.Fatalf("expected tries=%d, have tries=%d", i, b.n)
+		}
+	}
+}
+
+// Ensure that Schedule1 returns the duration it waits for.
+func TestSchedule1(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	for i := uint64(1); i <= 2; i++ {
+		start := time.Now()
+		dur, c := b.Schedule1()
+		if want := interval << (i - 1); dur != want {
+			t.Fatalf("want duration=%s, have %s", want, dur)
+		}
+
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatal("expected Schedule1 to fire")
+		}
+
+		if elapsed := time.Since(start); elapsed < dur {
+			t.Fatalf("expected to wait at least %s, waited %s", dur, elapsed)
+		}
+
+		if b.n != i {
+			t.Fatalf("expected tries=%d, have tries=%d", i, b.n)
+		}
+	}
+}
+
+// Ensure that DurationContext clamps the duration to the context's
+// deadline.
+func TestDurationContext(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewWithoutJitter(time.Hour, time.Second)
+	b.setClock(clock.Now)
+
+	if dur := b.DurationContext(context.Background()); dur != time.Second {
+		t.Fatalf("expected duration=%s without a deadline, have %s", time.Second, dur)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(500*time.Millisecond))
+	defer cancel()
+	if dur := b.DurationContext(ctx); dur != 500*time.Millisecond {
+		t.Fatalf("expected duration=%s, have %s", 500*time.Millisecond, dur)
+	}
+
+	clock.Advance(time.Second)
+	if dur := b.DurationContext(ctx); dur != 0 {
+		t.Fatalf("expected duration=0 after the deadline, have %s", dur)
+	}
+
+	if b.Tries() != 3 {
+		t.Fatalf("expected tries=3, have tries=%d", b.Tries())
+	}
+}
+
 func ExampleBackoff_SetDecay() {
 	b := NewWithoutJitter(max, interval)
 	b.SetDecay(decay)
@@ -173,3 +1631,24 @@ func ExampleBackoff_SetDecay() {
 	// 8ms
 	// 1ms
 }
+
+// Ensure that Duration doesn't allocate, with or without jitter, so
+// that it can be called in tight loops.
+func TestDurationAllocs(t *testing.T) {
+	for _, b := range []*Backoff{New(time.Hour, time.Millisecond), NewWithoutJitter(time.Hour, time.Millisecond)} {
+		b.Duration()
+		if allocs := testing.AllocsPerRun(100, func() { b.Duration() }); allocs != 0 {
+			t.Fatalf("want 0 allocations per Duration, have %v (jitter=%v)", allocs, b.JitterEnabled())
+		}
+	}
+}
+
+// BenchmarkDuration measures the plain jittered case; it should report
+// 0 allocs/op.
+func BenchmarkDuration(b *testing.B) {
+	bo := New(time.Hour, time.Millisecond)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = bo.Duration()
+	}
+}
@@ -0,0 +1,216 @@
+// Package backoffhttp provides an http.RoundTripper that retries
+// requests, using a backoff.Backoff to space out the attempts.
+package backoffhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudflare/backoff"
+)
+
+// DefaultStatusCodes are the response status codes that are retried
+// when a Transport's StatusCodes is nil.
+var DefaultStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultMaxTries is the number of retries made when a Transport's
+// NewBackoff is nil.
+const DefaultMaxTries = 3
+
+// A Transport is an http.RoundTripper that retries idempotent requests
+// which fail with a connection error or a retryable status code.
+//
+// Requests with a method other than GET, HEAD, OPTIONS, TRACE, PUT or
+// DELETE are sent once, without retrying. The body of a retried
+// request is buffered in memory so that it can be sent again, unless
+// the request has a GetBody function.
+//
+// Before each retry, the Transport waits for the duration returned by
+// the request's Backoff, or the delay given by a Retry-After header in
+// the response if that is longer. If the Retry-After delay is longer
+// than the Backoff's max duration, the Transport doesn't retry, and
+// returns the response instead, so that the caller can decide whether
+// to wait that long. It also gives up once the Backoff is exhausted or
+// its budget is exceeded, or the request's context is done.
+type Transport struct {
+	// Transport is used to send each attempt. If it is nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// NewBackoff returns a new Backoff for each request. If it is
+	// nil, a request is retried up to DefaultMaxTries times, with a
+	// 100ms interval and a 10s max duration.
+	NewBackoff func() *backoff.Backoff
+
+	// StatusCodes are the response status codes that are retried.
+	// If it is nil, DefaultStatusCodes is used.
+	StatusCodes []int
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !idempotent(req.Method) {
+		return t.transport().RoundTrip(req)
+	}
+
+	getBody, err := bodyFunc(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b := t.newBackoff()
+	ctx := req.Context()
+	for {
+		attempt := req
+		if getBody != nil {
+			attempt = req.Clone(ctx)
+			if attempt.Body, err = getBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.transport().RoundTrip(attempt)
+		if err == nil && !t.retryable(resp.StatusCode) {
+			return resp, nil
+		}
+
+		if b.Exhausted() || b.BudgetExceeded() || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var hint time.Duration
+		if err == nil {
+			hint = retryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		// DurationWithHint caps the hint at the max duration, so a
+		// shorter duration means the server asked for a longer wait
+		// than the Backoff allows.
+		d := b.DurationWithHint(hint)
+		if d < hint {
+			return resp, nil
+		}
+
+		if err == nil {
+			drain(resp.Body)
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Transport
+}
+
+func (t *Transport) newBackoff() *backoff.Backoff {
+	if t.NewBackoff == nil {
+		return backoff.NewWithOptions(
+			backoff.WithInterval(100*time.Millisecond),
+			backoff.WithMaxDuration(10*time.Second),
+			backoff.WithMaxTries(DefaultMaxTries),
+		)
+	}
+
+	return t.NewBackoff()
+}
+
+func (t *Transport) retryable(code int) bool {
+	codes := t.StatusCodes
+	if codes == nil {
+		codes = DefaultStatusCodes
+	}
+
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// bodyFunc returns a function that returns a fresh copy of the
+// request's body, buffering it if needed. It returns nil if the
+// request has no body.
+func bodyFunc(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	// Each attempt is sent with a fresh copy of the body, so the
+	// original is no longer needed.
+	defer req.Body.Close()
+
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}, nil
+}
+
+// retryAfter parses the value of a Retry-After header, which is
+// either a number of seconds or an HTTP date. It returns zero if the
+// value is missing or invalid.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// drain reads the rest of a response body and closes it, so that the
+// connection can be reused.
+func drain(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 4096))
+	body.Close()
+}
@@ -0,0 +1,216 @@
+package backoffhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/backoff"
+)
+
+func newTestBackoff() *backoff.Backoff {
+	b := backoff.NewWithoutJitter(10*time.Millisecond, time.Millisecond)
+	b.SetMaxTries(3)
+	return b
+}
+
+// Ensure that requests are retried on retryable status codes, with the
+// body replayed on each attempt.
+func TestTransportRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "hello" {
+			t.Errorf("expected body %q, have %q", "hello", body)
+		}
+
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{NewBackoff: newTestBackoff}}
+	req, err := http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("expected request to succeed, have %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, have %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected calls=3, have calls=%d", calls)
+	}
+}
+
+// Ensure that the last response is returned once the backoff is
+// exhausted.
+func TestTransportExhausted(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{NewBackoff: newTestBackoff}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected a response, have %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected status %d, have %d", http.StatusBadGateway, resp.StatusCode)
+	}
+
+	if calls != 4 {
+		t.Fatalf("expected calls=4, have calls=%d", calls)
+	}
+}
+
+// Non-idempotent requests should not be retried.
+func TestTransportNonIdempotent(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{NewBackoff: newTestBackoff}}
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("expected a response, have %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 1 {
+		t.Fatalf("expected calls=1, have calls=%d", calls)
+	}
+}
+
+// Ensure that a Retry-After header extends the wait.
+func TestTransportRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{
+		NewBackoff: func() *backoff.Backoff {
+			return backoff.NewWithoutJitter(time.Minute, time.Millisecond)
+		},
+	}}
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected request to succeed, have %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected to wait at least 1s, waited %s", elapsed)
+	}
+}
+
+// Ensure that a Retry-After delay longer than the max duration isn't
+// waited for, and that the response is returned instead.
+func TestTransportRetryAfterTooLong(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{NewBackoff: newTestBackoff}}
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the response to be returned, have %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want status=%d, have %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("want calls=1, have calls=%d", n)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected not to wait for the Retry-After delay, waited %s", elapsed)
+	}
+}
+
+// Ensure that the request's context cancels the wait between attempts.
+func TestTransportContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{
+		NewBackoff: func() *backoff.Backoff {
+			return backoff.NewWithoutJitter(time.Hour, time.Hour)
+		},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Do(req)
+	if err == nil {
+		t.Fatal("expected request to fail")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-1", 0},
+		{"soon", 0},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		if d := retryAfter(tt.value); d != tt.expected {
+			t.Fatalf("expected retryAfter(%q)=%s, have %s", tt.value, tt.expected, d)
+		}
+	}
+}
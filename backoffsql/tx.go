@@ -0,0 +1,39 @@
+// Package backoffsql provides helpers for retrying database/sql
+// transactions, using a backoff.Backoff to space out the attempts.
+package backoffsql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cloudflare/backoff"
+)
+
+// RunTx runs fn in a transaction, retrying with a fresh transaction if
+// it fails with an error for which retryable returns true, such as a
+// deadlock or serialization failure.
+//
+// Each attempt begins a transaction on db and calls fn with it. If fn
+// succeeds, the transaction is committed; otherwise it is rolled back.
+// Errors from beginning or committing the transaction are retried in
+// the same way as errors from fn, as some databases only report
+// serialization failures on commit. Attempts are spaced out as with
+// backoff.Retry, which resets b on success and gives up once b is
+// exhausted, its budget is exceeded or ctx is done, returning the last
+// error. If retryable is nil, every error is retried, except those
+// wrapped with backoff.Permanent.
+func RunTx(ctx context.Context, db *sql.DB, b *backoff.Backoff, retryable func(error) bool, fn func(*sql.Tx) error) error {
+	return backoff.Retry(ctx, b, func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	}, backoff.WithRetryableFunc(retryable))
+}
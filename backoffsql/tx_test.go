@@ -0,0 +1,139 @@
+package backoffsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/backoff"
+)
+
+var errDeadlock = errors.New("deadlock detected")
+
+// testDriver is a database/sql driver whose transactions only record
+// whether they were committed or rolled back.
+type testDriver struct {
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+func (d *testDriver) Open(name string) (driver.Conn, error) {
+	return &testConn{d: d}, nil
+}
+
+type testConn struct {
+	d *testDriver
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	return &testTx{d: c.d}, nil
+}
+
+type testTx struct {
+	d *testDriver
+}
+
+func (tx *testTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+
+	tx.d.commits++
+	return nil
+}
+
+func (tx *testTx) Rollback() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+
+	tx.d.rollbacks++
+	return nil
+}
+
+var testDB = &testDriver{}
+
+func init() {
+	sql.Register("backoffsql-test", testDB)
+}
+
+func newTestBackoff() *backoff.Backoff {
+	b := backoff.NewWithoutJitter(10*time.Millisecond, time.Millisecond)
+	b.SetMaxTries(3)
+	return b
+}
+
+func isDeadlock(err error) bool {
+	return err == errDeadlock
+}
+
+// Ensure that a transaction failing with a retryable error is rolled
+// back and retried in a fresh transaction, which is then committed.
+func TestRunTxRetries(t *testing.T) {
+	db, err := sql.Open("backoffsql-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	*testDB = testDriver{}
+	var txs []*sql.Tx
+	err = RunTx(context.Background(), db, newTestBackoff(), isDeadlock, func(tx *sql.Tx) error {
+		for _, prev := range txs {
+			if prev == tx {
+				t.Error("expected a fresh transaction for each attempt")
+			}
+		}
+
+		txs = append(txs, tx)
+		if len(txs) < 3 {
+			return errDeadlock
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, have %v", err)
+	}
+
+	if len(txs) != 3 || testDB.commits != 1 || testDB.rollbacks != 2 {
+		t.Fatalf("expected attempts=3, commits=1, rollbacks=2, have attempts=%d, commits=%d, rollbacks=%d",
+			len(txs), testDB.commits, testDB.rollbacks)
+	}
+}
+
+// Ensure that a transaction failing with an error that isn't retryable
+// is rolled back and not retried.
+func TestRunTxNotRetryable(t *testing.T) {
+	db, err := sql.Open("backoffsql-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	*testDB = testDriver{}
+	errTest := errors.New("constraint violation")
+	var calls int
+	err = RunTx(context.Background(), db, newTestBackoff(), isDeadlock, func(tx *sql.Tx) error {
+		calls++
+		return errTest
+	})
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if calls != 1 || testDB.commits != 0 || testDB.rollbacks != 1 {
+		t.Fatalf("expected calls=1, commits=0, rollbacks=1, have calls=%d, commits=%d, rollbacks=%d",
+			calls, testDB.commits, testDB.rollbacks)
+	}
+}
@@ -0,0 +1,71 @@
+// Package backofftest provides helpers for testing code that uses
+// package backoff.
+package backofftest
+
+import (
+	"sync"
+
+	"github.com/cloudflare/backoff"
+)
+
+// A Source is a backoff.RandSource that returns pre-scripted values,
+// so that tests can assert exact jittered durations. It is safe for
+// concurrent use.
+type Source struct {
+	mu     sync.Mutex
+	values []int64
+	next   int
+	strict bool
+}
+
+var _ backoff.RandSource = (*Source)(nil)
+
+// FixedSource returns a Source that returns values in order from
+// Int63n, starting again from the first value once they have all been
+// returned; use Strict to panic instead. A value that is not in [0, n)
+// for the n passed to Int63n is clamped to that range, so a value of
+// math.MaxInt64 always selects the longest duration.
+//
+// Panics if no values are given.
+func FixedSource(values ...int64) *Source {
+	if len(values) == 0 {
+		panic("backofftest: no values for fixed source")
+	}
+
+	return &Source{values: append([]int64(nil), values...)}
+}
+
+// Strict makes s panic once its values have all been returned, rather
+// than starting again, so that tests notice when more durations are
+// computed than expected. It returns s.
+func (s *Source) Strict() *Source {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.strict = true
+	return s
+}
+
+// Int63n returns the next value, clamped to [0, n).
+func (s *Source) Int63n(n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == len(s.values) {
+		if s.strict {
+			panic("backofftest: fixed source exhausted")
+		}
+		s.next = 0
+	}
+
+	v := s.values[s.next]
+	s.next++
+	switch {
+	case v < 0:
+		return 0
+	case v >= n:
+		return n - 1
+	}
+
+	return v
+}
@@ -0,0 +1,48 @@
+package backofftest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/backoff"
+)
+
+// Ensure that a Backoff using a FixedSource returns exactly the
+// scripted durations, cycling through them.
+func TestFixedSource(t *testing.T) {
+	b := backoff.New(time.Hour, 100*time.Millisecond)
+	b.SetRandSource(FixedSource(10, math.MaxInt64, -1))
+
+	expected := []time.Duration{
+		10,
+		200*time.Millisecond - 1,
+		0,
+		10,
+	}
+
+	for i, want := range expected {
+		if have := b.Duration(); have != want {
+			t.Fatalf("want duration=%s, have %s at i=%d", want, have, i)
+		}
+	}
+}
+
+// Ensure that a strict FixedSource panics once it is exhausted.
+func TestFixedSourceStrict(t *testing.T) {
+	s := FixedSource(1, 2).Strict()
+	if v := s.Int63n(10); v != 1 {
+		t.Fatalf("want 1, have %d", v)
+	}
+
+	if v := s.Int63n(10); v != 2 {
+		t.Fatalf("want 2, have %d", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected an exhausted strict source to panic")
+		}
+	}()
+	s.Int63n(10)
+}
@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package backoff
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoEndpoints is returned by RetryEndpoints if it is given no
+// endpoints.
+var ErrNoEndpoints = errors.New("backoff: no endpoints")
+
+// RetryEndpoints works like Retry, but calls fn with each of endpoints
+// in turn, such as the replicas of a service, and only waits for the
+// next backoff duration once a full pass over them has failed; each
+// pass starts again from the first endpoint. Once fn succeeds, b is
+// reset and RetryEndpoints returns nil.
+//
+// Errors are handled as by Retry, and RetryEndpoints gives up under
+// the same conditions, returning the last error returned by fn. It
+// also gives up between endpoints if ctx is done.
+func RetryEndpoints[T any](ctx context.Context, b *Backoff, endpoints []T, fn func(T) error, opts ...RetryOption) error {
+	if len(endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+
+	c := newRetryConfig(opts)
+	for {
+		var err error
+		for i, endpoint := range endpoints {
+			if i > 0 && ctx.Err() != nil {
+				return err
+			}
+
+			err = fn(endpoint)
+			if err == nil {
+				b.Reset()
+				return nil
+			}
+
+			if terr := c.terminal(err); terr != nil {
+				return terr
+			}
+		}
+
+		d, ok := b.DurationOrAbort()
+		if !ok || sleep(ctx, d) != nil {
+			return err
+		}
+	}
+}
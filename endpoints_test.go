@@ -0,0 +1,78 @@
+//go:build go1.18
+// +build go1.18
+
+package backoff
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Ensure that RetryEndpoints tries every endpoint before backing off,
+// and starts each pass from the first endpoint.
+func TestRetryEndpoints(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var tried []string
+	err := RetryEndpoints(context.Background(), b, []string{"a", "b", "c"}, func(endpoint string) error {
+		tried = append(tried, endpoint)
+		if len(tried) < 5 {
+			return errTest
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, have %v", err)
+	}
+
+	if have := strings.Join(tried, ","); have != "a,b,c,a,b" {
+		t.Fatalf("want endpoints tried=a,b,c,a,b, have %s", have)
+	}
+
+	if b.Tries() != 0 {
+		t.Fatalf("expected the backoff to be reset, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that RetryEndpoints backs off once for each failed pass, and
+// gives up when the backoff is exhausted.
+func TestRetryEndpointsExhausted(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetMaxTries(2)
+
+	var calls int
+	err := RetryEndpoints(context.Background(), b, []int{1, 2}, func(int) error {
+		calls++
+		return errTest
+	})
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if calls != 6 || b.Tries() != 2 {
+		t.Fatalf("expected calls=6, tries=2, have calls=%d, tries=%d", calls, b.Tries())
+	}
+
+	if err := RetryEndpoints(context.Background(), b, nil, func(int) error { return nil }); err != ErrNoEndpoints {
+		t.Fatalf("expected %v, have %v", ErrNoEndpoints, err)
+	}
+}
+
+// Ensure that RetryEndpoints stops between endpoints once the context
+// is done.
+func TestRetryEndpointsContext(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	err := RetryEndpoints(ctx, b, []int{1, 2, 3}, func(int) error {
+		calls++
+		cancel()
+		return errTest
+	})
+	if err != errTest || calls != 1 {
+		t.Fatalf("expected err=%v, calls=1, have err=%v, calls=%d", errTest, err, calls)
+	}
+}
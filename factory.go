@@ -0,0 +1,24 @@
+package backoff
+
+// A Factory creates Backoffs sharing the same configuration, so that a
+// service with many retry sites can define its backoff policy in one
+// place. Unlike a Pool, each Backoff it creates is new and owned by the
+// caller. A Factory is safe for concurrent use.
+type Factory struct {
+	template *Backoff
+}
+
+// NewFactory returns a Factory of Backoffs configured like template,
+// including any callbacks set with SetOnRetry or SetLogger. Later
+// changes to template do not affect the factory. Each Backoff is
+// created as by Clone, so it gets its own source of randomness, even if
+// template was seeded.
+func NewFactory(template *Backoff) *Factory {
+	return &Factory{template: template.Clone()}
+}
+
+// New returns a new Backoff with the factory's configuration and no
+// tries.
+func (f *Factory) New() *Backoff {
+	return f.template.Clone()
+}
@@ -0,0 +1,57 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that backoffs from a factory have the template's
+// configuration, and are independent of each other and of the
+// template.
+func TestFactory(t *testing.T) {
+	template := NewWithoutJitter(time.Minute, time.Second)
+	template.SetMaxTries(3)
+	f := NewFactory(template)
+	template.SetInterval(time.Hour)
+
+	b1, b2 := f.New(), f.New()
+	if b1 == b2 {
+		t.Fatal("expected the factory to return a new backoff each time")
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = b1.Duration()
+	}
+
+	if !b1.Exhausted() {
+		t.Fatal("expected the backoff to be exhausted after 3 tries")
+	}
+
+	if b2.Tries() != 0 {
+		t.Fatalf("expected tries=0 for the second backoff, have tries=%d", b2.Tries())
+	}
+
+	if dur := b2.Duration(); dur != time.Second {
+		t.Fatalf("expected duration=%s, have %s", time.Second, dur)
+	}
+}
+
+// Ensure that backoffs from a factory built from a simulation share
+// its virtual clock.
+func TestFactorySimulation(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	template := NewSimulation(time.Minute, time.Second, 1, start)
+	template.SetDecay(time.Second)
+	b := NewFactory(template).New()
+
+	_ = b.Duration()
+	_ = b.Duration()
+	b.AdvanceTo(start.Add(time.Hour))
+	if dur := b.Duration(); dur > time.Second {
+		t.Fatalf("expected the backoff to decay, have duration=%s", dur)
+	}
+
+	if b.Tries() != 1 {
+		t.Fatalf("want tries=1, have tries=%d", b.Tries())
+	}
+}
@@ -0,0 +1,165 @@
+package backoff
+
+import (
+	"math"
+	"time"
+)
+
+// growthMode selects how the duration of a Backoff grows with the
+// number of tries.
+type growthMode int
+
+const (
+	// exponentialGrowth multiplies the interval by factor^n.
+	exponentialGrowth growthMode = iota
+
+	// fibonacciGrowth multiplies the interval by the nth Fibonacci
+	// number.
+	fibonacciGrowth
+
+	// linearGrowth multiplies the interval by n+1.
+	linearGrowth
+
+	// constantGrowth always uses the interval.
+	constantGrowth
+
+	// rampDownGrowth divides the interval by factor^n.
+	rampDownGrowth
+
+	// phasedGrowth grows linearly for the first switchAfter tries,
+	// and exponentially after that.
+	phasedGrowth
+)
+
+// SetFibonacci switches the Backoff to Fibonacci growth, so that the
+// nth duration is interval * Fib(n), capped at the max duration, where
+// the sequence starts 1, 1, 2, 3, 5, 8. This grows more slowly than
+// doubling. Jitter is applied in the same way as for exponential
+// growth.
+func (b *Backoff) SetFibonacci() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.growth = fibonacciGrowth
+}
+
+// SetLinear switches the Backoff to linear growth, so that the nth
+// duration is interval * (n+1), capped at the max duration. That is,
+// the durations grow arithmetically (1x, 2x, 3x the interval, and so
+// on) rather than geometrically. Jitter is applied in the same way as
+// for exponential growth.
+func (b *Backoff) SetLinear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.growth = linearGrowth
+}
+
+// SetConstant switches the Backoff to a constant duration, so that
+// every duration is the interval, capped at the max duration, with
+// jitter applied if it is enabled. The number of tries is still
+// counted.
+func (b *Backoff) SetConstant() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.growth = constantGrowth
+}
+
+// SetRampDown switches the Backoff to ramp down rather than back off,
+// so that the nth duration is interval / factor^n, where the factor
+// is DefaultFactor unless it has been changed with SetFactor. The
+// durations shrink towards the minimum duration set with SetMin, or
+// 1ns if there is none. This is useful for speeding up, such as when
+// draining a queue.
+func (b *Backoff) SetRampDown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.growth = rampDownGrowth
+}
+
+// SetPhased switches the Backoff to two-phase growth: the first
+// switchAfter durations grow linearly, as with SetLinear, and the
+// durations after that grow exponentially by the factor, starting from
+// the last linear one, so that there is no drop at the handoff. That
+// is, with an interval of 1s, a factor of 2 and switchAfter of 3, the
+// durations are 1s, 2s, 3s, 6s, 12s, capped at the max duration. This
+// retries quickly on brief failures while still backing off during
+// longer outages. Jitter is applied in the same way as for exponential
+// growth, and a switchAfter of 0 is equivalent to exponential growth.
+func (b *Backoff) SetPhased(switchAfter uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.growth = phasedGrowth
+	b.switchAfter = switchAfter
+}
+
+// phased returns the nth two-phase duration.
+//
+// requires b to be locked.
+func (b *Backoff) phased(n uint64) time.Duration {
+	if n < b.switchAfter {
+		return b.scale(n + 1)
+	}
+
+	if b.switchAfter == 0 {
+		return exponential(n, b.interval, b.maxDuration, b.factor)
+	}
+
+	return exponential(n-b.switchAfter+1, b.scale(b.switchAfter), b.maxDuration, b.factor)
+}
+
+// rampDown returns the nth ramp-down duration.
+//
+// requires b to be locked.
+func (b *Backoff) rampDown(n uint64) time.Duration {
+	t := time.Duration(float64(b.interval) / math.Pow(b.factor, float64(n)))
+	switch {
+	case t < 1:
+		return 1
+	case t > b.maxDuration:
+		return b.maxDuration
+	default:
+		return t
+	}
+}
+
+// scaleLimit returns the largest multiple of the interval that
+// doesn't exceed the max duration.
+//
+// requires b to be locked.
+func (b *Backoff) scaleLimit() uint64 {
+	return uint64(b.maxDuration / b.interval)
+}
+
+// scale returns the interval multiplied by k, saturating at the max
+// duration.
+//
+// requires b to be locked.
+func (b *Backoff) scale(k uint64) time.Duration {
+	if k > b.scaleLimit() {
+		return b.maxDuration
+	}
+
+	return b.interval * time.Duration(k)
+}
+
+// fibonacci returns the nth Fibonacci number, starting from 1, 1. The
+// sequence is computed iteratively, stopping early once it exceeds
+// limit, in which case the returned value is only guaranteed to be
+// greater than limit.
+func fibonacci(n, limit uint64) uint64 {
+	a, b := uint64(1), uint64(1)
+	for ; n > 0; n-- {
+		// a <= b <= limit, so a+b can't overflow as limit is
+		// at most math.MaxInt64.
+		if b > limit {
+			return b
+		}
+		a, b = b, a+b
+	}
+
+	return a
+}
@@ -0,0 +1,161 @@
+package backoff
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Ensure that Fibonacci growth follows the Fibonacci sequence until
+// the max duration.
+func TestFibonacci(t *testing.T) {
+	b := NewWithoutJitter(100, 3)
+	b.SetFibonacci()
+
+	expected := []time.Duration{3, 3, 6, 9, 15, 24, 39, 63, 100, 100}
+	for i, want := range expected {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", want, dur, i)
+		}
+	}
+}
+
+// Ensure that Fibonacci growth saturates at the max duration for very
+// large exponents rather than overflowing.
+func TestFibonacciSaturation(t *testing.T) {
+	b := NewWithoutJitter(time.Duration(math.MaxInt64), 1)
+	b.SetFibonacci()
+
+	for _, n := range []uint64{92, 93, 1000, math.MaxUint64} {
+		b.n = n
+		if dur := b.Duration(); dur != time.Duration(math.MaxInt64) {
+			t.Fatalf("want duration=%d, have duration=%d at n=%d", time.Duration(math.MaxInt64), dur, n)
+		}
+	}
+
+	// Fib(91) is the largest Fibonacci number below math.MaxInt64.
+	b.n = 91
+	if dur := b.Duration(); dur != 7540113804746346429 {
+		t.Fatalf("want duration=%d, have duration=%d", 7540113804746346429, dur)
+	}
+}
+
+// Ensure that linear growth adds the interval on each try until the
+// max duration.
+func TestLinear(t *testing.T) {
+	b := NewWithoutJitter(10, 3)
+	b.SetLinear()
+
+	expected := []time.Duration{3, 6, 9, 10, 10}
+	for i, want := range expected {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", want, dur, i)
+		}
+	}
+
+	b.n = math.MaxUint64
+	if dur := b.Duration(); dur != 10 {
+		t.Fatalf("want duration=10, have duration=%d", dur)
+	}
+}
+
+// Ensure that phased growth switches from linear to exponential growth
+// without a drop, and saturates at the max duration.
+func TestPhased(t *testing.T) {
+	b := NewWithoutJitter(100, 1)
+	b.SetPhased(3)
+
+	expected := []time.Duration{1, 2, 3, 6, 12, 24, 48, 96, 100, 100}
+	for i, want := range expected {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d", want, dur, i)
+		}
+	}
+
+	b.n = math.MaxUint64
+	if dur := b.Duration(); dur != 100 {
+		t.Fatalf("want duration=100, have duration=%d", dur)
+	}
+
+	b = NewWithoutJitter(100, 1)
+	b.SetPhased(0)
+	for i, want := range []time.Duration{1, 2, 4, 8} {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%d, have duration=%d at i=%d with switchAfter=0", want, dur, i)
+		}
+	}
+}
+
+// Ensure that jitter is applied to linear growth.
+func TestLinearJitter(t *testing.T) {
+	b := New(time.Second, time.Millisecond)
+	b.SetLinear()
+
+	for i := 1; i <= 100; i++ {
+		if dur := b.Duration(); dur >= time.Duration(i)*time.Millisecond {
+			t.Fatalf("want duration < %s, have %s", time.Duration(i)*time.Millisecond, dur)
+		}
+	}
+}
+
+// Ensure that constant growth always returns the interval, while still
+// counting tries.
+func TestConstant(t *testing.T) {
+	b := NewWithoutJitter(10, 3)
+	b.SetConstant()
+
+	for i := uint64(0); i < 5; i++ {
+		if dur := b.Duration(); dur != 3 {
+			t.Fatalf("want duration=3, have duration=%d at i=%d", dur, i)
+		}
+	}
+
+	if b.Tries() != 5 {
+		t.Fatalf("want tries=5, have tries=%d", b.Tries())
+	}
+
+	b = NewWithoutJitter(2, 3)
+	b.SetConstant()
+	if dur := b.Duration(); dur != 2 {
+		t.Fatalf("want duration=2, have duration=%d", dur)
+	}
+}
+
+// Ensure that ramping down halves the duration on each try, down to
+// the minimum.
+func TestRampDown(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, 64*time.Second)
+	b.SetRampDown()
+	b.SetMin(5 * time.Second)
+
+	expected := []time.Duration{64, 32, 16, 8, 5, 5}
+	for i, want := range expected {
+		want *= time.Second
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("want duration=%s, have %s at i=%d", want, dur, i)
+		}
+	}
+
+	b.n = math.MaxUint64
+	if dur := b.Duration(); dur != 5*time.Second {
+		t.Fatalf("want duration=%s, have %s", 5*time.Second, dur)
+	}
+}
+
+// Without a minimum, ramping down should bottom out at 1ns, even with
+// jitter and a custom factor.
+func TestRampDownFloor(t *testing.T) {
+	b := New(time.Hour, time.Second)
+	b.SetRampDown()
+	b.SetFactor(10)
+
+	for i := 0; i < 20; i++ {
+		if dur := b.Duration(); dur < 0 || dur >= time.Second {
+			t.Fatalf("want duration in [0, %s), have %s at i=%d", time.Second, dur, i)
+		}
+	}
+
+	if base := b.Peek(); base != 1 {
+		t.Fatalf("want duration=1ns, have %s", base)
+	}
+}
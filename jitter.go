@@ -0,0 +1,453 @@
+package backoff
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/big"
+	"time"
+)
+
+// A RandSource is a source of random numbers for jitter. It is
+// satisfied by *math/rand.Rand.
+type RandSource interface {
+	// Int63n returns a non-negative pseudo-random number in
+	// [0, n). It may panic if n <= 0.
+	Int63n(n int64) int64
+}
+
+// SetRandSource replaces the source of randomness used for jitter,
+// which is useful for deterministic tests. A nil source restores the
+// default, a math/rand source seeded from crypto/rand. The source is
+// only used while b is locked, so it doesn't need to be safe for
+// concurrent use unless it is shared with other Backoffs. It replaces
+// the source set by SetSecureRandom.
+func (b *Backoff) SetRandSource(r RandSource) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r == nil {
+		r = newRand()
+	}
+
+	b.rng = r
+	b.secureRandom = false
+}
+
+// SetSecureRandom replaces the source of randomness used for jitter
+// with one backed by crypto/rand, for deployments that mustn't use
+// math/rand. It is slower than the default source. If reading from
+// crypto/rand fails, which is very unlikely, the jitter is as long as
+// possible rather than random, so that the backoff errs on the side of
+// waiting longer. Backoffs created from b by Clone, Copy, NewPool and
+// NewFactory also use crypto/rand.
+func (b *Backoff) SetSecureRandom() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rng = secureSource{}
+	b.secureRandom = true
+}
+
+// randReader is the source of cryptographically secure random bytes,
+// for seeding and for SetSecureRandom; it is a variable so that tests
+// can replace it.
+var randReader io.Reader = rand.Reader
+
+// secureSource is a RandSource backed by crypto/rand.
+type secureSource struct{}
+
+func (secureSource) Int63n(n int64) int64 {
+	if n <= 0 {
+		panic("backoff: invalid argument to Int63n")
+	}
+
+	v, err := rand.Int(randReader, big.NewInt(n))
+	if err != nil {
+		return n - 1
+	}
+
+	return v.Int64()
+}
+
+// DurationKeyed works like Duration, but derives the jitter from key
+// rather than the source of randomness, so that the durations for a
+// given key, such as a shard ID, are reproducible while different keys
+// are still spread out. The random value is taken from the 64-bit
+// FNV-1a hash of key followed by the number of tries before this call,
+// as 8 big-endian bytes, so each attempt gets a different value. The
+// attempt counter is incremented as by Duration, and if jitter is
+// disabled, key has no effect.
+func (b *Backoff) DurationKeyed(key []byte) time.Duration {
+	b.mu.Lock()
+	b.setup()
+	h := fnv.New64a()
+	h.Write(key)
+	var attempt [8]byte
+	binary.BigEndian.PutUint64(attempt[:], b.tries)
+	h.Write(attempt[:])
+
+	rng := b.rng
+	b.rng = keyedSource(h.Sum64())
+	d := b.next()
+	b.rng = rng
+	b.unlockNotify(d)
+	return d
+}
+
+// keyedSource is a RandSource that always returns a value derived from
+// a hash.
+type keyedSource uint64
+
+func (s keyedSource) Int63n(n int64) int64 {
+	if n <= 0 {
+		panic("backoff: invalid argument to Int63n")
+	}
+
+	return int64(uint64(s)>>1) % n
+}
+
+// jitterMode selects how a Backoff randomises its durations.
+type jitterMode int
+
+const (
+	// fullJitter returns a random duration between 0 and the
+	// exponential duration.
+	fullJitter jitterMode = iota
+
+	// decorrelatedJitter returns a random duration between the
+	// interval and three times the previous duration.
+	decorrelatedJitter
+
+	// equalJitter returns half of the exponential duration plus a
+	// random duration between 0 and the other half.
+	equalJitter
+
+	// partialJitter randomises a fraction of the exponential
+	// duration, given by the jitter factor.
+	partialJitter
+
+	// proportionalJitter returns a random duration within a
+	// fraction of the exponential duration either side of it,
+	// given by the jitter factor.
+	proportionalJitter
+)
+
+// JitterEnabled returns true if the backoff applies jitter to its
+// durations; that is, unless it was created with NewWithoutJitter or
+// the WithoutJitter option, or jitter was disabled by SetJitter.
+func (b *Backoff) JitterEnabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.noJitter
+}
+
+// SetJitter enables or disables jitter, so that the choice doesn't
+// have to be made up front between New and NewWithoutJitter. The
+// jitter algorithm selected by SetDecorrelatedJitter, SetEqualJitter
+// or SetJitterFactor is kept, and used again once jitter is
+// re-enabled.
+func (b *Backoff) SetJitter(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.noJitter = !enabled
+}
+
+// SetDecorrelatedJitter switches the Backoff to the "Decorrelated
+// Jitter" algorithm described in the AWS architecture blog article.
+// Rather than growing exponentially with the number of tries, each
+// duration is a random value between the interval and three times the
+// previous duration, capped at the max duration:
+//
+//	sleep = min(max, random_between(interval, sleep * 3))
+//
+// This enables jitter if it was disabled.
+func (b *Backoff) SetDecorrelatedJitter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.noJitter = false
+	b.jitterMode = decorrelatedJitter
+}
+
+// SetEqualJitter switches the Backoff to the "Equal Jitter"
+// algorithm described in the AWS architecture blog article. Each
+// duration is half of the exponential duration plus a random value
+// between 0 and the other half, so that the duration is never less
+// than half of the exponential duration:
+//
+//	sleep = base/2 + random_between(0, base/2)
+//
+// This enables jitter if it was disabled.
+func (b *Backoff) SetEqualJitter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.noJitter = false
+	b.jitterMode = equalJitter
+}
+
+// SetJitterFactor sets the fraction of the exponential duration that
+// is randomised, so that each duration is
+//
+//	sleep = base*(1-f) + random_between(0, base*f)
+//
+// A factor of 1 is equivalent to full jitter, 0.5 to equal jitter and
+// 0 to no jitter. This enables jitter if it was disabled. Panics if f
+// is not between 0 and 1.
+func (b *Backoff) SetJitterFactor(f float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !(f >= 0 && f <= 1) {
+		panic("backoff: jitter factor is not between 0 and 1")
+	}
+
+	b.noJitter = false
+	b.jitterMode = partialJitter
+	b.jitterFactor = f
+}
+
+// SetProportionalJitter switches the Backoff to jitter within a band
+// around the exponential duration, so that each duration is
+//
+//	sleep = random_between(base*(1-pct), base*(1+pct))
+//
+// capped at the max duration and raised to the minimum, if any. This
+// keeps the durations close to the exponential curve, while still
+// spreading out clients. Unlike the other jitter algorithms, a
+// duration can be longer than the exponential duration, by up to pct,
+// but never longer than the max duration; Peek returns that upper
+// bound. This enables jitter if it was disabled. Panics if pct is not
+// between 0 and 1.
+func (b *Backoff) SetProportionalJitter(pct float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !(pct >= 0 && pct <= 1) {
+		panic("backoff: jitter factor is not between 0 and 1")
+	}
+
+	b.setup()
+	b.noJitter = false
+	b.jitterMode = proportionalJitter
+	b.jitterFactor = pct
+}
+
+// SetNonZeroJitter ensures that a jittered duration is never zero, so
+// that there is never an immediate retry. With full jitter, each
+// duration is then a random value between 1ns and the exponential
+// duration inclusive, rather than between 0 and just below it; the
+// other jitter algorithms are shifted up by 1ns in the same way. This
+// holds even when the exponential duration is only 1ns. It doesn't
+// enable jitter if it was disabled.
+func (b *Backoff) SetNonZeroJitter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nonZeroJitter = true
+}
+
+// SetInitialOffset adds a random offset between 0 and max inclusive to
+// the next duration only, capped at the max duration. Subsequent
+// durations are unaffected, including after Reset. Calling it when
+// workers start up staggers their backoffs, so that they don't all
+// retry in phase. Until the offset is used, Peek includes its upper
+// bound.
+//
+// Panics if max is negative.
+func (b *Backoff) SetInitialOffset(max time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if max < 0 {
+		panic("backoff: initial offset is negative")
+	}
+
+	b.setup()
+	b.offset = max
+}
+
+// addOffset adds a random offset of up to b.offset to t, and clears
+// the offset.
+//
+// requires b to be locked.
+func (b *Backoff) addOffset(t time.Duration) time.Duration {
+	n := int64(b.offset)
+	if n < math.MaxInt64 {
+		n++
+	}
+	b.offset = 0
+
+	off := time.Duration(b.rng.Int63n(n))
+	if off > b.maxDuration-t {
+		return b.maxDuration
+	}
+
+	return t + off
+}
+
+// SetJitterCap caps the upper bound of each jittered duration at d,
+// without changing the max duration, which still applies without
+// jitter. This allows a large max duration while keeping each
+// jittered duration bounded, which is mostly useful with decorrelated
+// jitter, as its bound grows threefold on each try. If both are set,
+// whichever of d and the max duration is smaller takes precedence. A
+// value of 0, the default, disables the cap.
+//
+// Panics if d is negative.
+func (b *Backoff) SetJitterCap(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if d < 0 {
+		panic("backoff: jitter cap is negative")
+	}
+
+	b.jitterCap = d
+}
+
+// proportionalBand returns the band around the exponential duration t
+// that proportional jitter picks a duration from, capped at the max
+// duration and the jitter cap.
+//
+// requires b to be locked
+func (b *Backoff) proportionalBand(t time.Duration) (lo, hi time.Duration) {
+	lo = time.Duration(math.Round(float64(t) * (1 - b.jitterFactor)))
+	hi = b.maxDuration
+	if t-lo < b.maxDuration-t {
+		hi = t + (t - lo)
+	}
+
+	if b.jitterCap != 0 && hi > b.jitterCap {
+		hi = b.jitterCap
+	}
+
+	return lo, hi
+}
+
+// applyJitter randomises the exponential duration t according to the
+// jitter mode.
+//
+// requires b to be locked
+func (b *Backoff) applyJitter(t time.Duration) time.Duration {
+	// There is no range to randomise over, and Int63n would panic,
+	// so return the shortest non-zero duration instead.
+	if t <= 0 && b.jitterMode != decorrelatedJitter {
+		return 1
+	}
+
+	if b.jitterCap != 0 && t > b.jitterCap {
+		t = b.jitterCap
+	}
+
+	switch b.jitterMode {
+	case decorrelatedJitter:
+		return b.decorrelated()
+	case equalJitter:
+		return b.between(t/2, t)
+	case partialJitter:
+		fixed := time.Duration(math.Round(float64(t) * (1 - b.jitterFactor)))
+		if fixed >= t {
+			return t
+		}
+		return b.between(fixed, t)
+	case proportionalJitter:
+		lo, hi := b.proportionalBand(t)
+		if lo >= hi {
+			return hi
+		}
+		return b.between(lo, hi)
+	default:
+		return b.between(0, t)
+	}
+}
+
+// between returns a random duration in [lo, hi), or in (lo, hi] if
+// non-zero jitter is set. If lo is not less than hi, it returns lo.
+//
+// requires b to be locked
+func (b *Backoff) between(lo, hi time.Duration) time.Duration {
+	if lo >= hi {
+		return lo
+	}
+
+	d := lo + time.Duration(b.rng.Int63n(int64(hi-lo)))
+	if b.nonZeroJitter {
+		d++
+	}
+
+	return d
+}
+
+// SampleDurations returns count independent samples of the duration
+// for the given attempt, counting from zero, with jitter applied by
+// the backoff's own source of randomness, so that the distribution of
+// the jitter can be tested. Apart from drawing random numbers, it
+// doesn't change the state of the backoff. With decorrelated jitter,
+// the samples follow from the current previous duration, whatever the
+// attempt. SetInitialOffset, SetFirstDelay and SetMonotonic, which
+// depend on the state of the backoff, are not applied.
+func (b *Backoff) SampleDurations(attempt uint64, count int) []time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if count <= 0 {
+		return nil
+	}
+
+	b.setup()
+	t := b.duration(attempt)
+	samples := make([]time.Duration, count)
+	for i := range samples {
+		d := t
+		switch {
+		case b.noJitter:
+		case b.jitterMode == decorrelatedJitter:
+			d = b.between(b.interval, b.decorrelatedBound(b.prev))
+		default:
+			d = b.applyJitter(t)
+		}
+
+		samples[i] = b.floor(d)
+	}
+
+	return samples
+}
+
+// requires b to be locked
+func (b *Backoff) decorrelated() time.Duration {
+	t := b.decorrelatedBound(b.prev)
+	b.atCeiling = t >= b.maxDuration
+	if t > b.interval {
+		t = b.between(b.interval, t)
+	}
+
+	b.prev = t
+	return t
+}
+
+// decorrelatedBound returns the upper bound of the decorrelated
+// jitter duration following prev.
+//
+// requires b to be locked
+func (b *Backoff) decorrelatedBound(prev time.Duration) time.Duration {
+	if prev < b.interval {
+		prev = b.interval
+	}
+
+	bound := b.maxDuration
+	if b.jitterCap != 0 && b.jitterCap < bound {
+		bound = b.jitterCap
+	}
+
+	if prev > bound/3 {
+		return bound
+	}
+
+	return prev * 3
+}
@@ -0,0 +1,509 @@
+package backoff
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// Ensure that decorrelated jitter stays within its documented bounds:
+// each duration is between the interval and three times the previous
+// duration, and never exceeds the max duration.
+func TestDecorrelatedJitter(t *testing.T) {
+	const iter = 10000
+
+	b := New(time.Second, time.Millisecond)
+	b.SetDecorrelatedJitter()
+
+	prev := time.Millisecond
+	for i := 0; i < iter; i++ {
+		dur := b.Duration()
+		if dur < time.Millisecond {
+			t.Fatalf("want duration >= %s, have %s at i=%d", time.Millisecond, dur, i)
+		}
+
+		if dur > time.Second {
+			t.Fatalf("want duration <= %s, have %s at i=%d", time.Second, dur, i)
+		}
+
+		if dur > prev*3 {
+			t.Fatalf("want duration <= %s, have %s at i=%d", prev*3, dur, i)
+		}
+		prev = dur
+	}
+}
+
+// Ensure that decorrelated jitter is capped at the max duration even
+// when the interval exceeds it.
+func TestDecorrelatedJitterCapped(t *testing.T) {
+	b := New(time.Millisecond, time.Second)
+	b.SetDecorrelatedJitter()
+
+	for i := 0; i < 10; i++ {
+		if dur := b.Duration(); dur != time.Millisecond {
+			t.Fatalf("want duration=%s, have %s at i=%d", time.Millisecond, dur, i)
+		}
+	}
+}
+
+// A reset should restart decorrelated jitter from the interval.
+func TestDecorrelatedJitterReset(t *testing.T) {
+	b := New(time.Hour, time.Millisecond)
+	b.SetDecorrelatedJitter()
+
+	for i := 0; i < 100; i++ {
+		_ = b.Duration()
+	}
+
+	b.Reset()
+	if dur := b.Duration(); dur > 3*time.Millisecond {
+		t.Fatalf("want duration <= %s after reset, have %s", 3*time.Millisecond, dur)
+	}
+}
+
+// Ensure that equal jitter never returns less than half of the
+// exponential duration, nor more than the exponential duration.
+func TestEqualJitter(t *testing.T) {
+	const iter = 1000
+
+	b := New(time.Second, time.Millisecond)
+	b.SetEqualJitter()
+
+	for i := 0; i < iter; i++ {
+		base := b.Peek()
+		dur := b.Duration()
+		if dur < base/2 || dur > base {
+			t.Fatalf("want duration between %s and %s, have %s at i=%d", base/2, base, dur, i)
+		}
+	}
+}
+
+// Ensure that partial jitter only randomises the given fraction of the
+// exponential duration.
+func TestJitterFactor(t *testing.T) {
+	const iter = 1000
+
+	b := New(time.Second, time.Millisecond)
+	b.SetJitterFactor(0.25)
+
+	for i := 0; i < iter; i++ {
+		base := b.Peek()
+		dur := b.Duration()
+		if dur < base*3/4 || dur > base {
+			t.Fatalf("want duration between %s and %s, have %s at i=%d", base*3/4, base, dur, i)
+		}
+	}
+
+	b.Reset()
+	b.SetJitterFactor(0)
+	for i := 0; i < 10; i++ {
+		base := b.Peek()
+		if dur := b.Duration(); dur != base {
+			t.Fatalf("want duration=%s without jitter, have %s at i=%d", base, dur, i)
+		}
+	}
+}
+
+// SetJitterFactor should reject factors outside [0, 1].
+func TestJitterFactorInvalid(t *testing.T) {
+	for _, f := range []float64{-0.1, 1.1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected SetJitterFactor(%v) to panic", f)
+				}
+			}()
+			new(Backoff).SetJitterFactor(f)
+		}()
+	}
+}
+
+// fixedSource is a RandSource that always returns n-1.
+type fixedSource struct{}
+
+func (fixedSource) Int63n(n int64) int64 {
+	return n - 1
+}
+
+// Ensure that a custom RandSource is used for jitter, and that a nil
+// source restores the default.
+func TestSetRandSource(t *testing.T) {
+	b := New(time.Second, time.Millisecond)
+	b.SetRandSource(fixedSource{})
+
+	for i := 0; i < 5; i++ {
+		base := b.Peek()
+		if dur := b.Duration(); dur != base-1 {
+			t.Fatalf("want duration=%s, have %s at i=%d", base-1, dur, i)
+		}
+	}
+
+	b.SetRandSource(nil)
+	if _, ok := b.rng.(fixedSource); ok || b.rng == nil {
+		t.Fatal("expected the default source to be restored")
+	}
+	_ = b.Duration()
+}
+
+// Ensure that DurationKeyed returns the same durations for the same
+// key, different durations for different keys, and advances the
+// attempt counter.
+func TestDurationKeyed(t *testing.T) {
+	b1 := New(time.Hour, time.Second)
+	b2 := New(time.Hour, time.Second)
+	for i := 0; i < 10; i++ {
+		d1 := b1.DurationKeyed([]byte("shard-1"))
+		d2 := b2.DurationKeyed([]byte("shard-1"))
+		if d1 != d2 {
+			t.Fatalf("expected equal durations for the same key at i=%d, have %s and %s", i, d1, d2)
+		}
+	}
+
+	if b1.Tries() != 10 {
+		t.Fatalf("want tries=10, have tries=%d", b1.Tries())
+	}
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 10; i++ {
+		b := New(time.Hour, time.Second)
+		b.Duration()
+		b.Duration()
+		seen[b.DurationKeyed([]byte("shard-"+strconv.Itoa(i)))] = true
+	}
+
+	if len(seen) < 5 {
+		t.Fatalf("expected different keys to give spread out durations, have %d distinct of 10", len(seen))
+	}
+}
+
+// zeroSource is a RandSource that always returns 0.
+type zeroSource struct{}
+
+func (zeroSource) Int63n(n int64) int64 {
+	return 0
+}
+
+// Ensure that non-zero jitter never returns a zero duration, and never
+// exceeds the exponential duration, even when it is only 1ns.
+func TestSetNonZeroJitter(t *testing.T) {
+	b := New(time.Second, time.Nanosecond)
+	b.SetRandSource(zeroSource{})
+	if dur := b.Duration(); dur != 0 {
+		t.Fatalf("expected duration=0 without non-zero jitter, have %s", dur)
+	}
+
+	b.Reset()
+	b.SetNonZeroJitter()
+	if dur := b.Duration(); dur != time.Nanosecond {
+		t.Fatalf("want duration=1ns, have %s", dur)
+	}
+
+	b.Reset()
+	b.SetRandSource(fixedSource{})
+	for i := 0; i < 5; i++ {
+		base := b.Peek()
+		if dur := b.Duration(); dur != base {
+			t.Fatalf("want duration=%s, have %s at i=%d", base, dur, i)
+		}
+	}
+
+	b.Reset()
+	b.SetEqualJitter()
+	b.SetRandSource(zeroSource{})
+	if dur := b.Duration(); dur != time.Nanosecond {
+		t.Fatalf("want duration=1ns with equal jitter, have %s", dur)
+	}
+}
+
+// Ensure that JitterEnabled reflects the constructor used.
+func TestJitterEnabled(t *testing.T) {
+	if !New(max, interval).JitterEnabled() {
+		t.Fatal("expected jitter to be enabled by New")
+	}
+
+	if NewWithoutJitter(max, interval).JitterEnabled() {
+		t.Fatal("expected jitter to be disabled by NewWithoutJitter")
+	}
+
+	if NewWithOptions(WithoutJitter()).JitterEnabled() {
+		t.Fatal("expected jitter to be disabled by WithoutJitter")
+	}
+}
+
+// Ensure that SetJitter toggles jitter on an existing backoff.
+func TestSetJitter(t *testing.T) {
+	b := New(max, interval)
+	b.SetRandSource(fixedSource{})
+
+	b.SetJitter(false)
+	if b.JitterEnabled() {
+		t.Fatal("expected jitter to be disabled")
+	}
+
+	if dur := b.Duration(); dur != interval {
+		t.Fatalf("want duration=%s, have %s", interval, dur)
+	}
+
+	b.SetJitter(true)
+	if !b.JitterEnabled() {
+		t.Fatal("expected jitter to be enabled")
+	}
+
+	if dur := b.Duration(); dur != 2*interval-1 {
+		t.Fatalf("want duration=%s, have %s", 2*interval-1, dur)
+	}
+}
+
+// Ensure that the initial offset is only added to the first duration,
+// and is capped at the max duration.
+func TestSetInitialOffset(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetRandSource(fixedSource{})
+	b.SetInitialOffset(2 * interval)
+
+	// fixedSource picks the largest offset, so Peek's upper bound
+	// matches the next duration exactly.
+	if peek := b.Peek(); peek != 3*interval {
+		t.Fatalf("want peek=%s, have %s", 3*interval, peek)
+	}
+
+	if dur := b.Duration(); dur != 3*interval {
+		t.Fatalf("want duration=%s, have %s", 3*interval, dur)
+	}
+
+	if dur := b.Duration(); dur != 2*interval {
+		t.Fatalf("want duration=%s, have %s", 2*interval, dur)
+	}
+
+	b.Reset()
+	if dur := b.Duration(); dur != interval {
+		t.Fatalf("want duration=%s after Reset, have %s", interval, dur)
+	}
+
+	b.SetInitialOffset(2 * max)
+	if peek := b.Peek(); peek != max {
+		t.Fatalf("want peek=%s, have %s", max, peek)
+	}
+
+	if dur := b.Duration(); dur != max {
+		t.Fatalf("want duration=%s, have %s", max, dur)
+	}
+}
+
+// Ensure that the jitter cap bounds jittered durations, but not
+// durations without jitter.
+func TestSetJitterCap(t *testing.T) {
+	b := New(time.Hour, time.Second)
+	b.SetRandSource(fixedSource{})
+	b.SetDecorrelatedJitter()
+	b.SetJitterCap(5 * time.Second)
+
+	for i := 0; i < 5; i++ {
+		if dur := b.Duration(); dur > 5*time.Second {
+			t.Fatalf("expected duration <= 5s, have %s at i=%d", dur, i)
+		}
+	}
+
+	if dur := b.Peek(); dur != 5*time.Second {
+		t.Fatalf("want peek=5s, have %s", dur)
+	}
+
+	b = New(time.Hour, time.Second)
+	b.SetRandSource(fixedSource{})
+	b.SetJitterCap(5 * time.Second)
+	for i := 0; i < 5; i++ {
+		if dur := b.Duration(); dur >= 5*time.Second {
+			t.Fatalf("expected duration < 5s, have %s at i=%d", dur, i)
+		}
+	}
+
+	b.SetJitter(false)
+	if dur := b.Duration(); dur != 32*time.Second {
+		t.Fatalf("want duration=32s without jitter, have %s", dur)
+	}
+}
+
+// Ensure that proportional jitter stays within the band around the
+// exponential duration, capped at the max duration.
+func TestSetProportionalJitter(t *testing.T) {
+	const iter = 1000
+
+	b := New(time.Hour, time.Second)
+	b.SetProportionalJitter(0.2)
+	var above bool
+	for i := 0; i < iter; i++ {
+		b.Reset()
+		dur := b.Duration()
+		if dur < 800*time.Millisecond || dur >= 1200*time.Millisecond {
+			t.Fatalf("expected 800ms <= duration < 1.2s, have %s", dur)
+		}
+		above = above || dur > time.Second
+	}
+
+	if !above {
+		t.Fatal("expected some durations to be longer than the interval")
+	}
+
+	b.Reset()
+	if peek := b.Peek(); peek != 1200*time.Millisecond {
+		t.Fatalf("want peek=%s, have %s", 1200*time.Millisecond, peek)
+	}
+
+	b = New(time.Second, time.Second)
+	b.SetProportionalJitter(0.2)
+	if peek := b.Peek(); peek != time.Second {
+		t.Fatalf("want peek=%s capped at the max, have %s", time.Second, peek)
+	}
+
+	for i := 0; i < iter; i++ {
+		if dur := b.Duration(); dur < 800*time.Millisecond || dur > time.Second {
+			t.Fatalf("expected 800ms <= duration <= 1s, have %s", dur)
+		}
+	}
+
+	b = New(time.Second, 100*time.Millisecond)
+	b.SetProportionalJitter(0)
+	if dur := b.Duration(); dur != 100*time.Millisecond {
+		t.Fatalf("want duration=100ms with pct=0, have %s", dur)
+	}
+}
+
+// Ensure that proportional jitter doesn't exceed the jitter cap, even
+// though its band extends above the capped duration.
+func TestProportionalJitterCap(t *testing.T) {
+	b := New(time.Hour, time.Second)
+	b.SetProportionalJitter(0.5)
+	b.SetJitterCap(2 * time.Second)
+	b.SetTries(3)
+	b.SetRandSource(fixedSource{})
+
+	for i := 0; i < 10; i++ {
+		if dur := b.Duration(); dur > 2*time.Second {
+			t.Fatalf("expected duration <= %s, have %s at i=%d", 2*time.Second, dur, i)
+		}
+	}
+}
+
+// Ensure that Peek applies the jitter cap, so that it returns the upper
+// bound of the next duration.
+func TestPeekJitterCap(t *testing.T) {
+	modes := []func(b *Backoff){
+		func(b *Backoff) {},
+		func(b *Backoff) { b.SetProportionalJitter(0.5) },
+	}
+
+	for i, mode := range modes {
+		b := New(time.Hour, time.Second)
+		mode(b)
+		b.SetJitterCap(2 * time.Second)
+		b.SetTries(5)
+		if peek := b.Peek(); peek != 2*time.Second {
+			t.Fatalf("want peek=%s, have %s for mode %d", 2*time.Second, peek, i)
+		}
+	}
+}
+
+// Ensure that the secure source returns values within range, and the
+// largest value if crypto/rand fails.
+func TestSetSecureRandom(t *testing.T) {
+	b := New(time.Second, time.Millisecond)
+	b.SetSecureRandom()
+	if _, ok := b.rng.(secureSource); !ok {
+		t.Fatalf("expected a secure source, have %T", b.rng)
+	}
+
+	for i := 0; i < 100; i++ {
+		base := b.Peek()
+		if dur := b.Duration(); dur < 0 || dur >= base {
+			t.Fatalf("expected 0 <= duration < %s, have %s at i=%d", base, dur, i)
+		}
+	}
+
+	copies := []*Backoff{b.Clone(), b.Copy(), NewFactory(b).New(), NewPool(b).Get()}
+	for i, c := range copies {
+		if _, ok := c.rng.(secureSource); !ok {
+			t.Fatalf("expected copy %d to have a secure source, have %T", i, c.rng)
+		}
+	}
+
+	b.SetRandSource(nil)
+	if c := b.Clone(); c.secureRandom {
+		t.Fatal("expected SetRandSource to replace the secure source")
+	}
+
+	b.SetSecureRandom()
+	defer func(r io.Reader) { randReader = r }(randReader)
+	randReader = iotest.ErrReader(errors.New("read failed"))
+
+	b.Reset()
+	if dur := b.Duration(); dur != time.Millisecond-1 {
+		t.Fatalf("want duration=%s, have %s", time.Millisecond-1, dur)
+	}
+}
+
+// Ensure that jitter doesn't panic if the duration is zero, whatever
+// the jitter mode, and returns 1ns instead.
+func TestJitterZeroDuration(t *testing.T) {
+	modes := []jitterMode{fullJitter, equalJitter, partialJitter, proportionalJitter}
+	for _, mode := range modes {
+		b := New(max, interval)
+		b.jitterMode = mode
+		b.jitterFactor = 0.5
+
+		if dur := b.applyJitter(0); dur != 1 {
+			t.Fatalf("want duration=1ns for jitter mode %d, have %s", mode, dur)
+		}
+	}
+
+	b := New(max, interval)
+	if d := b.between(interval, interval); d != interval {
+		t.Fatalf("want duration=%s, have %s", interval, d)
+	}
+}
+
+// Ensure that SampleDurations returns jittered samples for the given
+// attempt without changing the state of the backoff.
+func TestSampleDurations(t *testing.T) {
+	const count = 1000
+
+	b := New(time.Hour, time.Second)
+	samples := b.SampleDurations(3, count)
+	if len(samples) != count {
+		t.Fatalf("expected %d samples, have %d", count, len(samples))
+	}
+
+	distinct := make(map[time.Duration]bool)
+	for _, d := range samples {
+		if d < 0 || d >= 8*time.Second {
+			t.Fatalf("expected 0 <= sample < 8s, have %s", d)
+		}
+		distinct[d] = true
+	}
+
+	if len(distinct) < count/2 {
+		t.Fatalf("expected the samples to be mostly distinct, have %d distinct", len(distinct))
+	}
+
+	if b.Tries() != 0 || b.LastDuration() != 0 {
+		t.Fatalf("expected tries=0, last=0, have tries=%d, last=%s", b.Tries(), b.LastDuration())
+	}
+
+	b.SetDecorrelatedJitter()
+	for _, d := range b.SampleDurations(0, count) {
+		if d < time.Second || d >= 3*time.Second {
+			t.Fatalf("expected 1s <= sample < 3s, have %s", d)
+		}
+	}
+
+	if b.prev != 0 {
+		t.Fatalf("expected prev=0, have %s", b.prev)
+	}
+
+	if s := b.SampleDurations(0, 0); s != nil {
+		t.Fatalf("expected no samples, have %v", s)
+	}
+}
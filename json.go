@@ -0,0 +1,199 @@
+package backoff
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonConfig is the JSON representation of a Backoff's configuration.
+// Durations are represented as strings, as accepted by
+// time.ParseDuration.
+type jsonConfig struct {
+	Interval      string  `json:"interval,omitempty"`
+	MaxDuration   string  `json:"max_duration,omitempty"`
+	NoJitter      bool    `json:"no_jitter,omitempty"`
+	Monotonic     bool    `json:"monotonic,omitempty"`
+	QuickRecovery bool    `json:"quick_recovery,omitempty"`
+	Jitter        string  `json:"jitter,omitempty"`
+	JitterFactor  float64 `json:"jitter_factor,omitempty"`
+	NonZeroJitter bool    `json:"non_zero_jitter,omitempty"`
+	SecureRandom  bool    `json:"secure_random,omitempty"`
+	JitterCap     string  `json:"jitter_cap,omitempty"`
+	Growth        string  `json:"growth,omitempty"`
+	SwitchAfter   uint64  `json:"switch_after,omitempty"`
+	Factor        float64 `json:"factor,omitempty"`
+	MinDuration   string  `json:"min_duration,omitempty"`
+	FirstDelay    string  `json:"first_delay,omitempty"`
+	Decay         string  `json:"decay,omitempty"`
+	MaxTries      uint64  `json:"max_tries,omitempty"`
+	Breaker       uint64  `json:"breaker,omitempty"`
+	MaxExponent   uint64  `json:"max_exponent,omitempty"`
+	Budget        string  `json:"budget,omitempty"`
+	AdaptiveAlpha float64 `json:"adaptive_alpha,omitempty"`
+	AdaptiveScale float64 `json:"adaptive_scale,omitempty"`
+}
+
+var jitterModeNames = map[jitterMode]string{
+	fullJitter:         "full",
+	decorrelatedJitter: "decorrelated",
+	equalJitter:        "equal",
+	partialJitter:      "partial",
+	proportionalJitter: "proportional",
+}
+
+var growthModeNames = map[growthMode]string{
+	exponentialGrowth: "exponential",
+	fibonacciGrowth:   "fibonacci",
+	linearGrowth:      "linear",
+	constantGrowth:    "constant",
+	rampDownGrowth:    "ramp_down",
+	phasedGrowth:      "phased",
+}
+
+// MarshalJSON encodes the configuration of the backoff as JSON, with
+// durations encoded as strings such as "5m0s". The attempt counter and
+// other state are not encoded.
+func (b *Backoff) MarshalJSON() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	c := jsonConfig{
+		Interval:      b.interval.String(),
+		MaxDuration:   b.maxDuration.String(),
+		NoJitter:      b.noJitter,
+		Monotonic:     b.monotonic,
+		QuickRecovery: b.quickRecovery,
+		Jitter:        jitterModeNames[b.jitterMode],
+		JitterFactor:  b.jitterFactor,
+		NonZeroJitter: b.nonZeroJitter,
+		SecureRandom:  b.secureRandom,
+		Growth:        growthModeNames[b.growth],
+		SwitchAfter:   b.switchAfter,
+		Factor:        b.factor,
+		MaxTries:      b.maxTries,
+		Breaker:       b.breaker,
+		MaxExponent:   b.maxExponent,
+		AdaptiveAlpha: b.adaptAlpha,
+		AdaptiveScale: b.adaptScale,
+	}
+
+	if b.minDuration != 0 {
+		c.MinDuration = b.minDuration.String()
+	}
+
+	if b.jitterCap != 0 {
+		c.JitterCap = b.jitterCap.String()
+	}
+
+	if b.firstDelay != 0 {
+		c.FirstDelay = b.firstDelay.String()
+	}
+
+	if b.decay != 0 {
+		c.Decay = b.decay.String()
+	}
+
+	if b.budget != 0 {
+		c.Budget = b.budget.String()
+	}
+
+	return json.Marshal(c)
+}
+
+// UnmarshalJSON decodes a configuration encoded by MarshalJSON into
+// the backoff. Missing or zero values use the defaults, and the
+// configuration is validated in the same way as by the setters. The
+// attempt counter is left unchanged.
+func (b *Backoff) UnmarshalJSON(data []byte) error {
+	var c jsonConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	nb := &Backoff{
+		config: config{
+			noJitter:      c.NoJitter,
+			monotonic:     c.Monotonic,
+			quickRecovery: c.QuickRecovery,
+			jitterFactor:  c.JitterFactor,
+			nonZeroJitter: c.NonZeroJitter,
+			secureRandom:  c.SecureRandom,
+			switchAfter:   c.SwitchAfter,
+			factor:        c.Factor,
+			maxTries:      c.MaxTries,
+			breaker:       c.Breaker,
+			maxExponent:   c.MaxExponent,
+			adaptAlpha:    c.AdaptiveAlpha,
+			adaptScale:    c.AdaptiveScale,
+		},
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", c.Interval, &nb.interval},
+		{"max_duration", c.MaxDuration, &nb.maxDuration},
+		{"min_duration", c.MinDuration, &nb.minDuration},
+		{"first_delay", c.FirstDelay, &nb.firstDelay},
+		{"jitter_cap", c.JitterCap, &nb.jitterCap},
+		{"decay", c.Decay, &nb.decay},
+		{"budget", c.Budget, &nb.budget},
+	}
+
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("backoff: invalid %s: %v", d.name, err)
+		}
+		*d.dst = v
+	}
+
+	if c.Jitter != "" {
+		found := false
+		for mode, name := range jitterModeNames {
+			if name == c.Jitter {
+				nb.jitterMode = mode
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("backoff: invalid jitter %q", c.Jitter)
+		}
+	}
+
+	if c.Growth != "" {
+		found := false
+		for mode, name := range growthModeNames {
+			if name == c.Growth {
+				nb.growth = mode
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("backoff: invalid growth %q", c.Growth)
+		}
+	}
+
+	nb.setup()
+	if err := nb.validate(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setConfig(nb.config)
+	return nil
+}
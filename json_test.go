@@ -0,0 +1,113 @@
+package backoff
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Ensure that a backoff's configuration survives a round trip through
+// JSON, and that its state does not.
+func TestJSONRoundTrip(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.SetFactor(1.5)
+	b.SetMin(2 * time.Second)
+	b.SetDecay(time.Hour)
+	b.SetMaxTries(10)
+	_ = b.Duration()
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	expected := `{"interval":"1s","max_duration":"1m0s","no_jitter":true,"jitter":"full","growth":"exponential","factor":1.5,"min_duration":"2s","decay":"1h0m0s","max_tries":10}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, have %s", expected, data)
+	}
+
+	var nb Backoff
+	if err := json.Unmarshal(data, &nb); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if nb.interval != b.interval || nb.maxDuration != b.maxDuration ||
+		nb.noJitter != b.noJitter || nb.factor != b.factor ||
+		nb.minDuration != b.minDuration || nb.decay != b.decay ||
+		nb.maxTries != b.maxTries {
+		t.Fatalf("expected %+v, have %+v", b, &nb)
+	}
+
+	if nb.n != 0 {
+		t.Fatalf("expected tries=0, have tries=%d", nb.n)
+	}
+}
+
+// Ensure that SetSecureRandom survives a round trip through JSON.
+func TestJSONSecureRandom(t *testing.T) {
+	b := New(time.Minute, time.Second)
+	b.SetSecureRandom()
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	nb := New(time.Minute, time.Second)
+	if err := json.Unmarshal(data, nb); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if _, ok := nb.rng.(secureSource); !ok {
+		t.Fatalf("expected a secure source, have %T", nb.rng)
+	}
+}
+
+// Missing values should use the defaults.
+func TestJSONDefaults(t *testing.T) {
+	var b Backoff
+	if err := json.Unmarshal([]byte(`{"jitter":"equal","growth":"fibonacci"}`), &b); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if b.interval != DefaultInterval {
+		t.Fatalf("expected interval=%s, have %s", DefaultInterval, b.interval)
+	}
+
+	if b.maxDuration != DefaultMaxDuration {
+		t.Fatalf("expected max duration=%s, have %s", DefaultMaxDuration, b.maxDuration)
+	}
+
+	if b.jitterMode != equalJitter {
+		t.Fatalf("expected equal jitter, have %d", b.jitterMode)
+	}
+
+	if b.growth != fibonacciGrowth {
+		t.Fatalf("expected Fibonacci growth, have %d", b.growth)
+	}
+}
+
+// Invalid configurations should be rejected, leaving the backoff
+// unchanged.
+func TestJSONInvalid(t *testing.T) {
+	invalid := []string{
+		`{"interval":"soon"}`,
+		`{"interval":"-1s"}`,
+		`{"factor":0.5}`,
+		`{"max_duration":"1s","min_duration":"1m"}`,
+		`{"jitter":"some"}`,
+		`{"growth":"some"}`,
+		`[]`,
+	}
+
+	for _, data := range invalid {
+		b := NewWithoutJitter(time.Minute, time.Second)
+		if err := json.Unmarshal([]byte(data), b); err == nil {
+			t.Fatalf("expected unmarshalling %s to fail", data)
+		}
+
+		if b.interval != time.Second || b.maxDuration != time.Minute {
+			t.Fatalf("expected backoff to be unchanged after unmarshalling %s", data)
+		}
+	}
+}
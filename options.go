@@ -0,0 +1,167 @@
+package backoff
+
+import (
+	"errors"
+	"math"
+	mrand "math/rand"
+	"time"
+)
+
+// An Option configures a Backoff created with NewWithOptions.
+type Option func(*Backoff)
+
+// WithMaxDuration sets the maximum duration of the backoff. A zero
+// value uses DefaultMaxDuration.
+func WithMaxDuration(max time.Duration) Option {
+	return func(b *Backoff) {
+		b.maxDuration = max
+	}
+}
+
+// WithInterval sets the interval of the backoff. A zero value uses
+// DefaultInterval.
+func WithInterval(interval time.Duration) Option {
+	return func(b *Backoff) {
+		b.interval = interval
+	}
+}
+
+// WithoutJitter disables jitter.
+func WithoutJitter() Option {
+	return func(b *Backoff) {
+		b.noJitter = true
+	}
+}
+
+// WithFactor sets the growth factor of the backoff; see SetFactor.
+func WithFactor(factor float64) Option {
+	return func(b *Backoff) {
+		b.factor = factor
+	}
+}
+
+// WithMinDuration sets the minimum duration of the backoff; see
+// SetMin.
+func WithMinDuration(min time.Duration) Option {
+	return func(b *Backoff) {
+		b.minDuration = min
+	}
+}
+
+// WithDecay sets the decay of the backoff; see SetDecay.
+func WithDecay(decay time.Duration) Option {
+	return func(b *Backoff) {
+		b.decay = decay
+	}
+}
+
+// WithMaxTries sets the number of tries after which the backoff is
+// exhausted; see SetMaxTries.
+func WithMaxTries(n uint64) Option {
+	return func(b *Backoff) {
+		b.maxTries = n
+	}
+}
+
+// WithBudget sets the total time the backoff may spend backing off;
+// see SetBudget.
+func WithBudget(total time.Duration) Option {
+	return func(b *Backoff) {
+		b.budget = total
+	}
+}
+
+// WithSeed seeds the backoff's source of randomness with seed, so that
+// its jittered durations are reproducible; see NewWithSeed.
+func WithSeed(seed int64) Option {
+	return func(b *Backoff) {
+		b.rng = mrand.New(mrand.NewSource(seed))
+	}
+}
+
+// NewWithOptions creates a new backoff configured with opts. Options
+// that aren't given use the same defaults as New.
+//
+// Panics if the options are invalid, under the same conditions as
+// New and the corresponding setters.
+func NewWithOptions(opts ...Option) *Backoff {
+	b, err := NewValidated(opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return b
+}
+
+// NewValidated works like NewWithOptions, but returns an error
+// instead of panicking if the options are invalid, such as a min
+// duration greater than the max or a factor <= 1. It is intended for
+// backoffs built from untrusted configuration.
+func NewValidated(opts ...Option) (*Backoff, error) {
+	b := new(Backoff)
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.setup()
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Validate checks the configuration of the backoff, after applying
+// the defaults for zero values, and returns an error describing the
+// first problem found. It checks the same conditions as the setters,
+// without panicking, so it can be used to check a Backoff built from
+// untrusted configuration before using it.
+//
+// Validate also rejects an interval greater than the max duration,
+// which the setters and constructors accept, as every duration would
+// then be clamped to the max, which is almost certainly a mistake.
+func (b *Backoff) Validate() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	if err := b.validate(); err != nil {
+		return err
+	}
+
+	if b.interval > b.maxDuration {
+		return errors.New("backoff: interval > max")
+	}
+
+	return nil
+}
+
+// validate checks the configuration of the backoff.
+//
+// requires b to be locked.
+func (b *Backoff) validate() error {
+	switch {
+	case b.maxDuration < 0 || b.interval < 0:
+		return errors.New("backoff: max or interval is negative")
+	case b.decay < 0:
+		return errors.New("backoff: decay < 0")
+	case b.factor != 0 && (b.factor <= 1 || math.IsNaN(b.factor)):
+		return errors.New("backoff: factor <= 1")
+	case b.minDuration < 0 || b.minDuration > b.maxDuration:
+		return errors.New("backoff: min is negative or greater than max")
+	case b.budget < 0:
+		return errors.New("backoff: budget < 0")
+	case b.adaptScale < 0 || math.IsNaN(b.adaptScale):
+		return errors.New("backoff: adaptive scale < 0")
+	case b.adaptScale != 0 && !(b.adaptAlpha > 0 && b.adaptAlpha <= 1):
+		return errors.New("backoff: adaptive alpha is not between 0 and 1")
+	case b.jitterCap < 0:
+		return errors.New("backoff: jitter cap is negative")
+	case b.firstDelay < 0:
+		return errors.New("backoff: first delay is negative")
+	case !(b.jitterFactor >= 0 && b.jitterFactor <= 1):
+		return errors.New("backoff: jitter factor is not between 0 and 1")
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that options are applied to the new backoff.
+func TestNewWithOptions(t *testing.T) {
+	b := NewWithOptions(
+		WithMaxDuration(time.Minute),
+		WithInterval(time.Second),
+		WithoutJitter(),
+		WithFactor(3),
+		WithMinDuration(2*time.Second),
+		WithDecay(time.Hour),
+		WithMaxTries(5),
+		WithBudget(time.Hour),
+	)
+
+	if b.maxDuration != time.Minute {
+		t.Fatalf("expected max duration=%s, have %s", time.Minute, b.maxDuration)
+	}
+
+	if b.interval != time.Second {
+		t.Fatalf("expected interval=%s, have %s", time.Second, b.interval)
+	}
+
+	if !b.noJitter {
+		t.Fatal("backoff should have been initialised without jitter")
+	}
+
+	if b.decay != time.Hour {
+		t.Fatalf("expected decay=%s, have %s", time.Hour, b.decay)
+	}
+
+	if b.maxTries != 5 {
+		t.Fatalf("expected max tries=5, have %d", b.maxTries)
+	}
+
+	if b.budget != time.Hour {
+		t.Fatalf("expected budget=%s, have %s", time.Hour, b.budget)
+	}
+
+	expected := []time.Duration{2 * time.Second, 3 * time.Second, 9 * time.Second, 27 * time.Second, time.Minute}
+	for i, want := range expected {
+		if dur := b.Duration(); dur != want {
+			t.Fatalf("expected duration=%s, have %s at i=%d", want, dur, i)
+		}
+	}
+}
+
+// Without options, NewWithOptions should use the defaults.
+func TestNewWithOptionsDefaults(t *testing.T) {
+	b := NewWithOptions()
+
+	if b.maxDuration != DefaultMaxDuration {
+		t.Fatalf("expected max duration=%s, have %s", DefaultMaxDuration, b.maxDuration)
+	}
+
+	if b.interval != DefaultInterval {
+		t.Fatalf("expected interval=%s, have %s", DefaultInterval, b.interval)
+	}
+
+	if b.noJitter {
+		t.Fatal("backoff should have been initialised with jitter")
+	}
+}
+
+// NewWithOptions should panic on invalid options.
+func TestNewWithOptionsInvalid(t *testing.T) {
+	invalid := [][]Option{
+		{WithInterval(-1)},
+		{WithMaxDuration(-1)},
+		{WithFactor(0.5)},
+		{WithMaxDuration(time.Second), WithMinDuration(time.Minute)},
+		{WithDecay(-1)},
+		{WithBudget(-1)},
+	}
+
+	for i, opts := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected NewWithOptions to panic for options %d", i)
+				}
+			}()
+			NewWithOptions(opts...)
+		}()
+	}
+}
+
+// NewValidated should return an error on invalid options, and a
+// backoff otherwise.
+func TestNewValidated(t *testing.T) {
+	b, err := NewValidated(WithInterval(time.Second), WithMaxDuration(time.Minute))
+	if err != nil {
+		t.Fatalf("expected valid options to be accepted, have %v", err)
+	}
+
+	if b.interval != time.Second || b.maxDuration != time.Minute {
+		t.Fatalf("expected interval=%s, max=%s, have interval=%s, max=%s",
+			time.Second, time.Minute, b.interval, b.maxDuration)
+	}
+
+	invalid := [][]Option{
+		{WithFactor(1)},
+		{WithMaxDuration(time.Second), WithMinDuration(time.Minute)},
+		{WithBudget(-1)},
+	}
+
+	for i, opts := range invalid {
+		b, err := NewValidated(opts...)
+		if err == nil {
+			t.Fatalf("expected options %d to be invalid", i)
+		}
+
+		if b != nil {
+			t.Fatalf("expected no backoff for invalid options %d, have %v", i, b)
+		}
+	}
+}
+
+// Ensure that Validate reports invalid configurations without
+// panicking.
+func TestValidate(t *testing.T) {
+	if err := new(Backoff).Validate(); err != nil {
+		t.Fatalf("expected zero-value backoff to be valid, have %v", err)
+	}
+
+	if err := New(time.Minute, time.Minute).Validate(); err != nil {
+		t.Fatalf("expected interval = max to be valid, have %v", err)
+	}
+
+	invalid := []*Backoff{
+		New(time.Second, time.Minute),
+		{config: config{maxDuration: time.Second}},
+		{config: config{interval: -1}},
+		{config: config{maxDuration: -1}},
+		{config: config{factor: 1}},
+		{config: config{maxDuration: time.Second, minDuration: time.Minute}},
+		{config: config{decay: -1}},
+		{config: config{budget: -1}},
+		{config: config{jitterFactor: 2}},
+	}
+
+	for i, b := range invalid {
+		if err := b.Validate(); err == nil {
+			t.Fatalf("expected backoff %d to be invalid", i)
+		}
+	}
+}
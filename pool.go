@@ -0,0 +1,72 @@
+package backoff
+
+import (
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// A Pool is a set of reusable Backoffs sharing the same configuration,
+// which saves allocating (and seeding) a new Backoff for each
+// short-lived operation. A Pool is safe for concurrent use.
+type Pool struct {
+	template *Backoff
+	pool     sync.Pool
+}
+
+// pooledRand is the source of randomness a Pool gives its Backoffs,
+// which is kept when they are returned, unlike any other source.
+type pooledRand struct {
+	*mrand.Rand
+}
+
+// NewPool returns a Pool of Backoffs configured like template. Later
+// changes to template do not affect the pool.
+func NewPool(template *Backoff) *Pool {
+	p := &Pool{template: template.Clone()}
+	p.pool.New = func() interface{} {
+		b := p.template.Clone()
+		if r, ok := b.rng.(*mrand.Rand); ok {
+			b.rng = pooledRand{r}
+		}
+		return b
+	}
+
+	return p
+}
+
+// Get returns a Backoff from the pool, with the pool's configuration
+// and no tries.
+func (p *Pool) Get() *Backoff {
+	return p.pool.Get().(*Backoff)
+}
+
+// Put resets b, restoring the pool's configuration, and returns it to
+// the pool. Everything set on b since Get is discarded, including its
+// source of randomness, stats and the latency passed to Observe. b must
+// not be used after it has been returned to the pool.
+func (p *Pool) Put(b *Backoff) {
+	t := p.template
+	b.mu.Lock()
+	b.config = t.config
+	b.clock = t.clock
+	b.virtual = t.virtual
+	b.onRetry = t.onRetry
+	b.log = t.log
+	b.lastTry = time.Time{}
+	b.lastSuccess = time.Time{}
+	b.hist = nil
+	b.latency = 0
+	b.offset = 0
+	b.resetCounters()
+	if _, ok := b.rng.(pooledRand); !ok {
+		b.rng = nil
+		b.setup()
+		if r, ok := b.rng.(*mrand.Rand); ok {
+			b.rng = pooledRand{r}
+		}
+	}
+	b.mu.Unlock()
+
+	p.pool.Put(b)
+}
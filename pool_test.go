@@ -0,0 +1,105 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that backoffs from a pool have the template's configuration,
+// and are reset when they are returned.
+func TestPool(t *testing.T) {
+	template := NewWithoutJitter(time.Minute, time.Second)
+	template.SetMaxTries(3)
+	p := NewPool(template)
+
+	b := p.Get()
+	if b.config != template.config {
+		t.Fatalf("expected config %+v, have %+v", template.config, b.config)
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+	b.SetInterval(time.Hour)
+	p.Put(b)
+
+	b = p.Get()
+	if b.config != template.config {
+		t.Fatalf("expected config %+v after put, have %+v", template.config, b.config)
+	}
+
+	if b.Tries() != 0 {
+		t.Fatalf("expected tries=0, have tries=%d", b.Tries())
+	}
+
+	if dur := b.Duration(); dur != time.Second {
+		t.Fatalf("expected duration=%s, have %s", time.Second, dur)
+	}
+}
+
+// Ensure that Put discards everything set on a backoff since Get, not
+// just its configuration and counters.
+func TestPoolPutState(t *testing.T) {
+	template := NewWithoutJitter(time.Minute, time.Second)
+	p := NewPool(template)
+
+	b := p.Get()
+	rng := b.rng
+	b.EnableStats()
+	b.SetAdaptive(0.5, 2)
+	b.Observe(time.Minute)
+	b.SetInitialOffset(time.Second)
+	_ = b.Duration()
+	b.MarkSuccess()
+	p.Put(b)
+
+	if b.hist != nil || b.latency != 0 || b.offset != 0 || !b.lastSuccess.IsZero() {
+		t.Fatalf("expected no stats, latency, offset or last success after put, have %+v", b)
+	}
+
+	if b.rng != rng {
+		t.Fatal("expected put to keep the pool's source of randomness")
+	}
+
+	b.SetRandSource(fixedSource{})
+	p.Put(b)
+	if _, ok := b.rng.(fixedSource); ok {
+		t.Fatal("expected put to discard a custom source of randomness")
+	}
+}
+
+// Ensure that backoffs from a pool built from a simulation share its
+// virtual clock.
+func TestPoolSimulation(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := NewPool(NewSimulation(time.Minute, time.Second, 1, start))
+
+	b := p.Get()
+	b.AdvanceTo(start.Add(time.Hour))
+	p.Put(b)
+
+	b = p.Get()
+	b.AdvanceTo(start.Add(2 * time.Hour))
+}
+
+func BenchmarkPool(b *testing.B) {
+	p := NewPool(New(time.Minute, time.Second))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			bo := p.Get()
+			_ = bo.Duration()
+			p.Put(bo)
+		}
+	})
+}
+
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			bo := New(time.Minute, time.Second)
+			_ = bo.Duration()
+		}
+	})
+}
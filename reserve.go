@@ -0,0 +1,75 @@
+package backoff
+
+import (
+	"math"
+	"time"
+)
+
+// A Reservation is a duration computed by Reserve, which only counts
+// as a try once it is confirmed. A Reservation must not be used from
+// multiple goroutines at once.
+type Reservation struct {
+	b         *Backoff
+	d         time.Duration
+	pre, post State
+	offset    time.Duration
+	confirmed bool
+}
+
+// Reserve computes the next duration, as Duration would, but without
+// counting it as a try: the number of tries and the rest of the state
+// of the backoff are only updated once Confirm is called on the
+// returned Reservation. This keeps the number of tries accurate when a
+// computed duration may be discarded, such as when the caller then
+// finds that its context is already done.
+func (b *Backoff) Reserve() *Reservation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The duration is only recorded in the stats once confirmed.
+	pre, offset, hist := b.state(), b.offset, b.hist
+	b.hist = nil
+	d := b.next()
+	r := &Reservation{b: b, d: d, pre: pre, post: b.state(), offset: b.offset}
+	b.loadState(pre)
+	b.offset, b.hist = offset, hist
+	return r
+}
+
+// Duration returns the reserved duration.
+func (r *Reservation) Duration() time.Duration {
+	return r.d
+}
+
+// Confirm counts the reserved duration as a try, updating the backoff
+// as if Duration had returned it, and calls the OnRetry callback and
+// the logger, if any. If the backoff has changed since the duration
+// was reserved, the try is counted on top of its current state instead.
+// Calling Confirm more than once has no further effect.
+func (r *Reservation) Confirm() {
+	if r.confirmed {
+		return
+	}
+	r.confirmed = true
+
+	b := r.b
+	b.mu.Lock()
+	if b.state() == r.pre {
+		b.loadState(r.post)
+		b.offset = r.offset
+		if b.hist != nil {
+			b.hist.add(r.d)
+		}
+	} else {
+		if b.n < math.MaxUint64 {
+			b.n++
+		}
+
+		if b.tries < math.MaxUint64 {
+			b.tries++
+		}
+		b.record(r.d)
+	}
+
+	b.unlockNotify(r.d)
+}
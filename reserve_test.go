@@ -0,0 +1,60 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that a reserved duration only counts as a try once it is
+// confirmed, and only once.
+func TestReserve(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	r := b.Reserve()
+	if r.Duration() != interval {
+		t.Fatalf("want duration=%s, have %s", interval, r.Duration())
+	}
+
+	if b.Tries() != 0 || b.LastDuration() != 0 {
+		t.Fatalf("expected tries=0, last=0 before Confirm, have tries=%d, last=%s", b.Tries(), b.LastDuration())
+	}
+
+	// Discarding a reservation leaves the backoff as it was.
+	if dur := b.Reserve().Duration(); dur != interval {
+		t.Fatalf("want duration=%s, have %s", interval, dur)
+	}
+
+	r.Confirm()
+	r.Confirm()
+	if b.Tries() != 1 || b.LastDuration() != interval {
+		t.Fatalf("expected tries=1, last=%s after Confirm, have tries=%d, last=%s",
+			interval, b.Tries(), b.LastDuration())
+	}
+
+	if dur := b.Duration(); dur != 2*interval {
+		t.Fatalf("want duration=%s, have %s", 2*interval, dur)
+	}
+}
+
+// Ensure that confirming a reservation after the backoff has changed
+// counts the try on top of its current state.
+func TestReserveChanged(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var attempts []uint64
+	b.SetOnRetry(func(attempt uint64, d time.Duration) {
+		attempts = append(attempts, attempt)
+	})
+
+	r := b.Reserve()
+	_ = b.Duration()
+	r.Confirm()
+
+	if b.Tries() != 2 || b.TotalElapsed() != 2*interval {
+		t.Fatalf("expected tries=2, total=%s, have tries=%d, total=%s", 2*interval, b.Tries(), b.TotalElapsed())
+	}
+
+	if len(attempts) != 2 || attempts[1] != 2 {
+		t.Fatalf("expected OnRetry to be called with attempts [1 2], have %v", attempts)
+	}
+}
@@ -0,0 +1,171 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+)
+
+// A PermanentError wraps an error returned by a function passed to
+// Retry, and signals that the operation should not be retried.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that Retry stops as soon as it is returned,
+// rather than backing off and trying again. Permanent returns nil if
+// err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentError{Err: err}
+}
+
+// A RetryOption configures Retry and its variants.
+type RetryOption func(*retryConfig)
+
+type retryConfig struct {
+	retryable func(error) bool
+}
+
+// WithRetryableFunc sets a function that decides whether an error
+// returned by fn should be retried. If it returns false, Retry stops
+// immediately and returns the error. By default, all errors are
+// retried, except those wrapped with Permanent, which are never
+// retried regardless of the function.
+func WithRetryableFunc(retryable func(error) bool) RetryOption {
+	return func(c *retryConfig) {
+		c.retryable = retryable
+	}
+}
+
+func newRetryConfig(opts []RetryOption) *retryConfig {
+	c := &retryConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// terminal returns the error to return if err shouldn't be retried,
+// or nil otherwise.
+func (c *retryConfig) terminal(err error) error {
+	var perr *PermanentError
+	if errors.As(err, &perr) {
+		return perr.Err
+	}
+
+	if c.retryable != nil && !c.retryable(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Retry calls fn until it returns a nil error, waiting for the next
+// backoff duration between failed attempts. Once fn succeeds, b is
+// reset and Retry returns nil.
+//
+// If fn returns an error wrapped with Permanent, Retry stops
+// immediately and returns the underlying error, and likewise if the
+// function set by WithRetryableFunc returns false for the error. If b
+// is exhausted (see SetMaxTries), its budget is exceeded (see
+// SetBudget) or ctx is done while waiting, Retry gives up and returns
+// the last error returned by fn.
+func Retry(ctx context.Context, b *Backoff, fn func() error, opts ...RetryOption) error {
+	c := newRetryConfig(opts)
+	for {
+		err := fn()
+		if err == nil {
+			b.Reset()
+			return nil
+		}
+
+		if terr := c.terminal(err); terr != nil {
+			return terr
+		}
+
+		d, ok := b.DurationOrAbort()
+		if !ok || sleep(ctx, d) != nil {
+			return err
+		}
+	}
+}
+
+// RetryN works like Retry, but calls fn at most n times, passing it the
+// index of the attempt, starting from 0. It returns nil as soon as fn
+// succeeds, or the last error returned by fn once n attempts have
+// failed. If n is not positive, fn is never called and RetryN returns
+// nil.
+func (b *Backoff) RetryN(ctx context.Context, n int, fn func(attempt int) error, opts ...RetryOption) error {
+	c := newRetryConfig(opts)
+	var err error
+	for attempt := 0; attempt < n; attempt++ {
+		err = fn(attempt)
+		if err == nil {
+			b.Reset()
+			return nil
+		}
+
+		if terr := c.terminal(err); terr != nil {
+			return terr
+		}
+
+		if attempt == n-1 {
+			break
+		}
+
+		d, ok := b.DurationOrAbort()
+		if !ok || sleep(ctx, d) != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// ErrExhausted is returned by Poll when the backoff is exhausted or its
+// budget is exceeded before the operation is done.
+var ErrExhausted = errors.New("backoff: exhausted")
+
+// Poll calls check until it reports that an operation is done, waiting
+// for the next backoff duration each time it isn't. Unlike with Retry,
+// a result that isn't done is not a failure but a sign of progress
+// still being made, so b is not reset when the operation is done.
+//
+// Poll returns nil once check returns true, or the error as soon as
+// check returns one, without retrying. It returns ctx.Err() if ctx is
+// done while waiting, and ErrExhausted if b is exhausted (see
+// SetMaxTries) or its budget is exceeded (see SetBudget).
+func (b *Backoff) Poll(ctx context.Context, check func() (done bool, err error)) error {
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+
+		d, ok := b.DurationOrAbort()
+		if !ok {
+			return ErrExhausted
+		}
+
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
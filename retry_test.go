@@ -0,0 +1,260 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("backoff: test error")
+
+// Ensure that Retry keeps calling fn until it succeeds, and resets the
+// backoff afterwards.
+func TestRetry(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var calls int
+	err := Retry(context.Background(), b, func() error {
+		calls++
+		if calls < 3 {
+			return errTest
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected nil error, have %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected calls=3, have calls=%d", calls)
+	}
+
+	if b.n != 0 {
+		t.Fatalf("expected tries=0 after success, have tries=%d", b.n)
+	}
+}
+
+// Ensure that Retry returns the last error from fn once the context
+// is cancelled.
+func TestRetryCancelled(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	err := Retry(ctx, b, func() error {
+		calls++
+		cancel()
+		return errTest
+	})
+
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected calls=1, have calls=%d", calls)
+	}
+}
+
+// Ensure that Retry stops as soon as fn returns a permanent error, and
+// returns the underlying error.
+func TestRetryPermanent(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, time.Hour)
+
+	var calls int
+	err := Retry(context.Background(), b, func() error {
+		calls++
+		return Permanent(errTest)
+	})
+
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected calls=1, have calls=%d", calls)
+	}
+}
+
+// Ensure that Retry stops as soon as the retryable function returns
+// false, and keeps retrying while it returns true.
+func TestRetryRetryableFunc(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	errStop := errors.New("stop")
+
+	var calls int
+	err := Retry(context.Background(), b, func() error {
+		calls++
+		if calls < 3 {
+			return errTest
+		}
+		return errStop
+	}, WithRetryableFunc(func(err error) bool {
+		return err != errStop
+	}))
+
+	if err != errStop {
+		t.Fatalf("expected %v, have %v", errStop, err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected calls=3, have calls=%d", calls)
+	}
+}
+
+// Ensure that Permanent errors can be seen through by the errors
+// package.
+func TestPermanentUnwrap(t *testing.T) {
+	err := Permanent(errTest)
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected errors.Is to find %v in %v", errTest, err)
+	}
+
+	if errors.Unwrap(err) != errTest {
+		t.Fatalf("expected unwrapped error to be %v, have %v", errTest, errors.Unwrap(err))
+	}
+
+	if Permanent(nil) != nil {
+		t.Fatal("expected Permanent(nil) to be nil")
+	}
+}
+
+// Ensure that Retry gives up once the backoff is exhausted.
+func TestRetryExhausted(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetMaxTries(2)
+
+	var calls int
+	err := Retry(context.Background(), b, func() error {
+		calls++
+		return errTest
+	})
+
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected calls=3, have calls=%d", calls)
+	}
+}
+
+// Ensure that Retry gives up once the budget is exceeded.
+func TestRetryBudget(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetBudget(3 * interval)
+
+	var calls int
+	err := Retry(context.Background(), b, func() error {
+		calls++
+		return errTest
+	})
+
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected calls=3, have calls=%d", calls)
+	}
+}
+
+// Ensure that RetryN calls fn at most n times, with the attempt index.
+func TestRetryN(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var attempts []int
+	err := b.RetryN(context.Background(), 3, func(attempt int) error {
+		attempts = append(attempts, attempt)
+		return errTest
+	})
+
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if len(attempts) != 3 || attempts[0] != 0 || attempts[2] != 2 {
+		t.Fatalf("expected attempts [0 1 2], have %v", attempts)
+	}
+
+	if b.n != 2 {
+		t.Fatalf("expected tries=2, have tries=%d", b.n)
+	}
+}
+
+// Ensure that RetryN returns nil as soon as fn succeeds.
+func TestRetryNSuccess(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var calls int
+	err := b.RetryN(context.Background(), 5, func(attempt int) error {
+		calls++
+		if attempt < 1 {
+			return errTest
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected nil error, have %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected calls=2, have calls=%d", calls)
+	}
+
+	if err := b.RetryN(context.Background(), 0, func(int) error { return errTest }); err != nil {
+		t.Fatalf("expected nil error without attempts, have %v", err)
+	}
+}
+
+// Ensure that Poll waits until the operation is done, without
+// resetting the backoff.
+func TestPoll(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var calls int
+	err := b.Poll(context.Background(), func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, have %v", err)
+	}
+
+	if calls != 3 || b.Tries() != 2 {
+		t.Fatalf("expected calls=3, tries=2, have calls=%d, tries=%d", calls, b.Tries())
+	}
+}
+
+// Ensure that Poll stops as soon as check returns an error, once the
+// backoff is exhausted, or once the context is done.
+func TestPollStops(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	err := b.Poll(context.Background(), func() (bool, error) {
+		return false, errTest
+	})
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	b.SetMaxTries(2)
+	err = b.Poll(context.Background(), func() (bool, error) {
+		return false, nil
+	})
+	if err != ErrExhausted {
+		t.Fatalf("expected %v, have %v", ErrExhausted, err)
+	}
+
+	b = NewWithoutJitter(time.Hour, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = b.Poll(ctx, func() (bool, error) {
+		return false, nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected %v, have %v", context.Canceled, err)
+	}
+}
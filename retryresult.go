@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package backoff
+
+import "context"
+
+// RetryResult works like Retry, but for functions that return a value
+// as well as an error. Once fn succeeds, RetryResult returns its value.
+// If Retry gives up, it returns the zero value and the error that
+// Retry would have returned.
+func RetryResult[T any](ctx context.Context, b *Backoff, fn func() (T, error), opts ...RetryOption) (T, error) {
+	var v T
+	err := Retry(ctx, b, func() error {
+		var err error
+		v, err = fn()
+		return err
+	}, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package backoff
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Ensure that RetryResult returns the value of the first successful
+// call.
+func TestRetryResult(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+
+	var calls int
+	v, err := RetryResult(context.Background(), b, func() (int, error) {
+		calls++
+		if calls < 3 {
+			return calls, errTest
+		}
+		return 42, nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, have %v", err)
+	}
+
+	if v != 42 || calls != 3 {
+		t.Fatalf("expected value=42, calls=3, have value=%d, calls=%d", v, calls)
+	}
+}
+
+// Ensure that RetryResult returns the zero value and the last error once
+// the context is done.
+func TestRetryResultContext(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	v, err := RetryResult(ctx, b, func() (string, error) {
+		return "partial", errTest
+	})
+	if err != errTest {
+		t.Fatalf("expected %v, have %v", errTest, err)
+	}
+
+	if v != "" {
+		t.Fatalf("expected the zero value, have %q", v)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build go1.23
+// +build go1.23
+
+package backoff
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// Seq returns an iterator over attempts at an operation, for use with
+// a range loop:
+//
+//	for attempt, d := range b.Seq(ctx) {
+//		if err := someOperation(); err == nil {
+//			break
+//		}
+//	}
+//
+// The first attempt is yielded immediately, as (0, 0). Each later
+// attempt is yielded after waiting for the next backoff duration, with
+// the number of tries so far and the duration waited. The iterator
+// stops once ctx is done, or the backoff is exhausted (see
+// SetMaxTries) or its budget is exceeded (see SetBudget). Breaking out
+// of the loop stops the iterator without waiting again.
+func (b *Backoff) Seq(ctx context.Context) iter.Seq2[uint64, time.Duration] {
+	return func(yield func(uint64, time.Duration) bool) {
+		if ctx.Err() != nil || !yield(0, 0) {
+			return
+		}
+
+		for {
+			attempt, d, ok := b.nextOrAbort()
+			if !ok || sleep(ctx, d) != nil || !yield(attempt, d) {
+				return
+			}
+		}
+	}
+}
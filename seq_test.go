@@ -0,0 +1,73 @@
+//go:build go1.23
+// +build go1.23
+
+package backoff
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Ensure that Seq yields each attempt with the duration waited, until
+// the backoff is exhausted.
+func TestSeq(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetMaxTries(3)
+
+	var attempts []uint64
+	var durations []time.Duration
+	for attempt, d := range b.Seq(context.Background()) {
+		attempts = append(attempts, attempt)
+		durations = append(durations, d)
+	}
+
+	expectedDurations := []time.Duration{0, interval, 2 * interval, 4 * interval}
+	if len(attempts) != len(expectedDurations) {
+		t.Fatalf("expected %d attempts, have %v", len(expectedDurations), attempts)
+	}
+
+	for i, want := range expectedDurations {
+		if attempts[i] != uint64(i) || durations[i] != want {
+			t.Fatalf("expected attempt=%d, duration=%s, have attempt=%d, duration=%s",
+				i, want, attempts[i], durations[i])
+		}
+	}
+}
+
+// Ensure that breaking out of the loop stops the iterator.
+func TestSeqBreak(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, time.Hour)
+
+	var calls int
+	for range b.Seq(context.Background()) {
+		calls++
+		break
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected calls=1, have calls=%d", calls)
+	}
+
+	if b.Tries() != 0 {
+		t.Fatalf("expected tries=0, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that Seq stops once the context is done.
+func TestSeqCancelled(t *testing.T) {
+	b := NewWithoutJitter(time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	for range b.Seq(ctx) {
+		calls++
+		cancel()
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected calls=1, have calls=%d", calls)
+	}
+}
@@ -0,0 +1,101 @@
+package backoff
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sharedWait is the state of the callers of SharedWait. It has its own
+// lock so that followers can wait on cond without holding up the rest
+// of the Backoff; mu is always taken before the Backoff's own lock.
+type sharedWait struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// waiting is true while a leader is sleeping until the end of
+	// the current window.
+	waiting bool
+
+	// until is the end of the current window.
+	until time.Time
+
+	// gen is incremented each time a window ends with its full
+	// duration having elapsed.
+	gen uint64
+}
+
+// SharedWait works like Wait, but coordinates concurrent callers so
+// that the attempt counter is only incremented once for each window:
+// the first caller computes the next duration and sleeps for it, and
+// any callers that arrive while it is sleeping wait for the same
+// window to end instead of computing durations of their own. It
+// returns nil once the window has ended, or ctx.Err() if ctx is done
+// first. If the caller that is sleeping gives up because its ctx is
+// done, one of the waiting callers sleeps for the rest of the window
+// in its place.
+func (b *Backoff) SharedWait(ctx context.Context) error {
+	s := &b.shared
+	s.mu.Lock()
+	if s.cond == nil {
+		s.cond = sync.NewCond(&s.mu)
+	}
+
+	joined := false
+	var gen uint64
+	for {
+		if joined && s.gen != gen {
+			s.mu.Unlock()
+			return nil
+		}
+
+		if !s.waiting {
+			var d time.Duration
+			if joined {
+				d = time.Until(s.until)
+			} else {
+				d = b.Duration()
+				s.until = time.Now().Add(d)
+			}
+
+			s.waiting = true
+			s.mu.Unlock()
+			err := sleep(ctx, d)
+
+			s.mu.Lock()
+			s.waiting = false
+			if err == nil {
+				s.gen++
+			}
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return err
+		}
+
+		if !joined {
+			joined = true
+			gen = s.gen
+
+			// Wake up this caller if ctx is done before the
+			// window ends.
+			stop := make(chan struct{})
+			defer close(stop)
+			go func() {
+				select {
+				case <-ctx.Done():
+					s.mu.Lock()
+					s.cond.Broadcast()
+					s.mu.Unlock()
+				case <-stop:
+				}
+			}()
+		}
+
+		if err := ctx.Err(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+
+		s.cond.Wait()
+	}
+}
@@ -0,0 +1,113 @@
+package backoff
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Ensure that concurrent callers of SharedWait share a single
+// duration, only incrementing the attempt counter once.
+func TestSharedWait(t *testing.T) {
+	b := NewWithoutJitter(time.Second, 50*time.Millisecond)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	start := time.Now()
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- b.SharedWait(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected SharedWait to return nil, have %v", err)
+		}
+	}
+
+	if b.Tries() != 1 {
+		t.Fatalf("want tries=1, have tries=%d", b.Tries())
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected to wait at least %s, have %s", 50*time.Millisecond, elapsed)
+	}
+
+	// A later call starts a new window.
+	if err := b.SharedWait(context.Background()); err != nil {
+		t.Fatalf("expected SharedWait to return nil, have %v", err)
+	}
+
+	if b.Tries() != 2 {
+		t.Fatalf("want tries=2, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that a waiting caller takes over the window if the caller
+// sleeping for it gives up, without incrementing the attempt counter
+// again.
+func TestSharedWaitLeaderCancelled(t *testing.T) {
+	b := NewWithoutJitter(time.Second, 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leader := make(chan error, 1)
+	go func() {
+		leader <- b.SharedWait(ctx)
+	}()
+
+	// Wait for the leader to start its window.
+	for b.Tries() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	follower := make(chan error, 1)
+	go func() {
+		follower <- b.SharedWait(context.Background())
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Fatalf("want err=%v, have %v", context.Canceled, err)
+	}
+
+	if err := <-follower; err != nil {
+		t.Fatalf("expected the follower to return nil, have %v", err)
+	}
+
+	if b.Tries() != 1 {
+		t.Fatalf("want tries=1, have tries=%d", b.Tries())
+	}
+}
+
+// Ensure that a waiting caller returns when its own ctx is done.
+func TestSharedWaitFollowerCancelled(t *testing.T) {
+	b := NewWithoutJitter(time.Second, 200*time.Millisecond)
+
+	leader := make(chan error, 1)
+	go func() {
+		leader <- b.SharedWait(context.Background())
+	}()
+
+	for b.Tries() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.SharedWait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want err=%v, have %v", context.DeadlineExceeded, err)
+	}
+
+	if err := <-leader; err != nil {
+		t.Fatalf("expected the leader to return nil, have %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package backoff
+
+import (
+	"sync"
+	"time"
+)
+
+// virtualClock is a clock that only moves when it is advanced.
+type virtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *virtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewSimulation works similarly to NewWithSeed, except that the
+// created Backoff uses a virtual clock starting at start, rather than
+// the system clock. The virtual clock only moves when AdvanceTo is
+// called, so that retry behaviour, including decay, can be simulated
+// deterministically and without sleeping. Clones of the Backoff share
+// its virtual clock.
+func NewSimulation(max time.Duration, interval time.Duration, seed int64, start time.Time) *Backoff {
+	b := NewWithSeed(max, interval, seed)
+	b.virtual = &virtualClock{now: start}
+	b.clock = b.virtual.Now
+	return b
+}
+
+// AdvanceTo moves the virtual clock of a Backoff created by
+// NewSimulation to t, as if the time between the last call and t had
+// passed.
+//
+// Panics if b doesn't have a virtual clock, or if t is before the
+// current virtual time.
+func (b *Backoff) AdvanceTo(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.virtual == nil {
+		panic("backoff: AdvanceTo called without a virtual clock")
+	}
+
+	b.virtual.mu.Lock()
+	defer b.virtual.mu.Unlock()
+
+	if t.Before(b.virtual.now) {
+		panic("backoff: AdvanceTo moves the virtual clock backwards")
+	}
+
+	b.virtual.now = t
+}
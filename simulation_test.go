@@ -0,0 +1,64 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that simulated backoffs with the same seed return the same
+// durations, and that decay is driven by the virtual clock.
+func TestSimulation(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b1 := NewSimulation(max, interval, 42, start)
+	b2 := NewSimulation(max, interval, 42, start)
+	b1.SetDecay(decay)
+	b2.SetDecay(decay)
+
+	now := start
+	for i := 0; i < 5; i++ {
+		d1, d2 := b1.Duration(), b2.Duration()
+		if d1 != d2 {
+			t.Fatalf("expected equal durations, have %s and %s at i=%d", d1, d2, i)
+		}
+
+		now = now.Add(d1)
+		b1.AdvanceTo(now)
+		b2.AdvanceTo(now)
+	}
+
+	if b1.Tries() != 5 {
+		t.Fatalf("expected tries=5, have tries=%d", b1.Tries())
+	}
+
+	b1.AdvanceTo(now.Add(max + decay + time.Second))
+	b1.Duration()
+	if b1.Tries() != 1 {
+		t.Fatalf("expected the backoff to decay to tries=1, have tries=%d", b1.Tries())
+	}
+}
+
+// Ensure that AdvanceTo panics without a virtual clock, or when moving
+// the clock backwards.
+func TestAdvanceToPanics(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name string
+		b    *Backoff
+		t    time.Time
+	}{
+		{"no virtual clock", New(max, interval), start},
+		{"backwards", NewSimulation(max, interval, 1, start), start.Add(-time.Second)},
+	}
+
+	for _, tc := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected AdvanceTo to panic: %s", tc.name)
+				}
+			}()
+
+			tc.b.AdvanceTo(tc.t)
+		}()
+	}
+}
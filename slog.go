@@ -0,0 +1,32 @@
+//go:build go1.21
+// +build go1.21
+
+package backoff
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SetLogger sets a logger that records each duration returned, at the
+// debug level, with the attributes attempt, duration and at_ceiling.
+// This is an alternative to SetOnRetry for logging. The record is
+// emitted after b has been unlocked. A nil logger disables logging.
+func (b *Backoff) SetLogger(logger *slog.Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if logger == nil {
+		b.log = nil
+		return
+	}
+
+	b.log = func(attempt uint64, d time.Duration, atCeiling bool) {
+		logger.LogAttrs(context.Background(), slog.LevelDebug, "backoff",
+			slog.Uint64("attempt", attempt),
+			slog.Duration("duration", d),
+			slog.Bool("at_ceiling", atCeiling),
+		)
+	}
+}
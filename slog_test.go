@@ -0,0 +1,46 @@
+//go:build go1.21
+// +build go1.21
+
+package backoff
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Ensure that each duration is logged at the debug level, and that a
+// nil logger disables logging.
+func TestSetLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	b := NewWithoutJitter(2*interval, interval)
+	b.SetLogger(logger)
+	_ = b.Duration()
+	_ = b.Duration()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"level=DEBUG msg=backoff attempt=1 duration=1ms at_ceiling=false",
+		"level=DEBUG msg=backoff attempt=2 duration=2ms at_ceiling=true",
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d records, have %q", len(expected), lines)
+	}
+
+	for i, want := range expected {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Fatalf("expected record to end with %q, have %q", want, lines[i])
+		}
+	}
+
+	buf.Reset()
+	b.SetLogger(nil)
+	_ = b.Duration()
+	if buf.Len() != 0 {
+		t.Fatalf("expected no records with a nil logger, have %q", buf.String())
+	}
+}
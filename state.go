@@ -0,0 +1,79 @@
+package backoff
+
+import "time"
+
+// State is the runtime state of a Backoff, as opposed to its
+// configuration. It can be saved with State and restored with
+// LoadState, such as to resume a backoff in another process after a
+// restart, and can be encoded as JSON.
+type State struct {
+	// Tries is the number of tries since the last reset.
+	Tries uint64 `json:"tries"`
+
+	// Exponent is the exponent that the next duration will be
+	// based on; see Backoff.Exponent.
+	Exponent uint64 `json:"exponent"`
+
+	// LastDuration is the last duration returned.
+	LastDuration time.Duration `json:"last_duration"`
+
+	// TotalElapsed is the sum of the durations returned; see
+	// Backoff.TotalElapsed.
+	TotalElapsed time.Duration `json:"total_elapsed"`
+
+	// Previous is the previous duration used by decorrelated
+	// jitter.
+	Previous time.Duration `json:"previous,omitempty"`
+
+	// AtCeiling is true if the last duration had reached the max
+	// duration; see Backoff.AtCeiling.
+	AtCeiling bool `json:"at_ceiling,omitempty"`
+
+	// LastTry is the time of the last try, which is used for
+	// decay; see Backoff.SetDecay.
+	LastTry time.Time `json:"last_try"`
+}
+
+// State returns the runtime state of the backoff, which can be restored
+// with LoadState. The configuration is not included; use MarshalJSON
+// to save it.
+func (b *Backoff) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state()
+}
+
+// LoadState restores runtime state returned by State, so that the
+// backoff continues where the saved one left off. The configuration of
+// the backoff is left unchanged.
+func (b *Backoff) LoadState(s State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.loadState(s)
+}
+
+// requires b to be locked.
+func (b *Backoff) state() State {
+	return State{
+		Tries:        b.tries,
+		Exponent:     b.n,
+		LastDuration: b.last,
+		TotalElapsed: b.total,
+		Previous:     b.prev,
+		AtCeiling:    b.atCeiling,
+		LastTry:      b.lastTry,
+	}
+}
+
+// requires b to be locked.
+func (b *Backoff) loadState(s State) {
+	b.tries = s.Tries
+	b.n = s.Exponent
+	b.last = s.LastDuration
+	b.total = s.TotalElapsed
+	b.prev = s.Previous
+	b.atCeiling = s.AtCeiling
+	b.lastTry = s.LastTry
+}
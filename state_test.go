@@ -0,0 +1,56 @@
+package backoff
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Ensure that a backoff restored from a saved state continues where
+// the saved one left off, including after a round trip through JSON.
+func TestStateRoundTrip(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	data, err := json.Marshal(b.State())
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if s != b.State() {
+		t.Fatalf("expected %+v, have %+v", b.State(), s)
+	}
+
+	nb := NewWithoutJitter(max, interval)
+	nb.LoadState(s)
+
+	if nb.Tries() != 3 || nb.LastDuration() != 4*interval || nb.TotalElapsed() != 7*interval {
+		t.Fatalf("expected tries=3, last=%s, total=%s, have tries=%d, last=%s, total=%s",
+			4*interval, 7*interval, nb.Tries(), nb.LastDuration(), nb.TotalElapsed())
+	}
+
+	if want, have := b.Duration(), nb.Duration(); want != have {
+		t.Fatalf("want duration=%s, have %s", want, have)
+	}
+}
+
+// Ensure that loading a zero State is equivalent to a reset.
+func TestLoadZeroState(t *testing.T) {
+	b := NewWithoutJitter(max, interval)
+	b.SetDecay(time.Hour)
+	for i := 0; i < 3; i++ {
+		_ = b.Duration()
+	}
+
+	b.LoadState(State{})
+	if dur := b.Duration(); dur != interval {
+		t.Fatalf("want duration=%s, have %s", interval, dur)
+	}
+}
@@ -0,0 +1,89 @@
+package backoff
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// histogram counts durations in buckets, each bounded by an upper
+// bound from bounds, in increasing order.
+type histogram struct {
+	bounds []time.Duration
+	counts []uint64
+	total  uint64
+}
+
+func (h *histogram) add(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	if i == len(h.bounds) {
+		i--
+	}
+
+	h.counts[i]++
+	h.total++
+}
+
+// EnableStats starts recording the durations returned by the backoff,
+// so that their distribution can be queried with Percentile. It is off
+// by default, as it adds a little overhead to each call to Duration.
+// The durations are counted in buckets whose upper bounds double from
+// the interval up to the max duration, as they are when EnableStats is
+// called. Calling EnableStats again clears the recorded durations.
+// Reset doesn't clear them.
+func (b *Backoff) EnableStats() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	var bounds []time.Duration
+	for d := b.interval; d < b.maxDuration; d *= 2 {
+		bounds = append(bounds, d)
+		if d > math.MaxInt64/2 {
+			break
+		}
+	}
+	bounds = append(bounds, b.maxDuration)
+
+	b.hist = &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// Percentile returns an estimate of the pth percentile of the durations
+// recorded since EnableStats was called, such as 95 for the duration
+// that 95% of them didn't exceed. The estimate is the upper bound of
+// the bucket that the percentile falls in, so it is never less than
+// the true value. It returns zero if stats aren't enabled or no
+// durations have been recorded.
+//
+// Panics if p is not between 0 and 100.
+func (b *Backoff) Percentile(p float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !(p >= 0 && p <= 100) {
+		panic("backoff: percentile is not between 0 and 100")
+	}
+
+	h := b.hist
+	if h == nil || h.total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return h.bounds[i]
+		}
+	}
+
+	return h.bounds[len(h.bounds)-1]
+}
@@ -0,0 +1,83 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that percentiles are estimated from the recorded durations.
+func TestPercentile(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	if p := b.Percentile(50); p != 0 {
+		t.Fatalf("expected 0 without stats, have %s", p)
+	}
+
+	b.EnableStats()
+	if p := b.Percentile(50); p != 0 {
+		t.Fatalf("expected 0 without durations, have %s", p)
+	}
+
+	// 1s, 2s, 4s, 8s, 16s, 32s, then 1m four times.
+	for i := 0; i < 10; i++ {
+		_ = b.Duration()
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Second},
+		{10, time.Second},
+		{50, 16 * time.Second},
+		{60, 32 * time.Second},
+		{95, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tc := range tests {
+		if have := b.Percentile(tc.p); have != tc.want {
+			t.Fatalf("want p%v=%s, have %s", tc.p, tc.want, have)
+		}
+	}
+
+	// Durations that don't fall on a bound are counted in the next
+	// bucket up.
+	b = New(time.Minute, time.Second)
+	b.EnableStats()
+	b.SetRandSource(fixedSource{})
+	_ = b.Duration()
+	_ = b.Duration()
+	if have := b.Percentile(100); have != 2*time.Second {
+		t.Fatalf("want p100=%s, have %s", 2*time.Second, have)
+	}
+}
+
+// Percentile should reject percentiles outside [0, 100].
+func TestPercentileInvalid(t *testing.T) {
+	for _, p := range []float64{-1, 101} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected Percentile(%v) to panic", p)
+				}
+			}()
+			new(Backoff).Percentile(p)
+		}()
+	}
+}
+
+// Ensure that reserved durations are only recorded once confirmed.
+func TestPercentileReserve(t *testing.T) {
+	b := NewWithoutJitter(time.Minute, time.Second)
+	b.EnableStats()
+
+	_ = b.Reserve()
+	if b.hist.total != 0 {
+		t.Fatalf("expected no durations before Confirm, have %d", b.hist.total)
+	}
+
+	b.Reserve().Confirm()
+	if b.hist.total != 1 {
+		t.Fatalf("expected 1 duration after Confirm, have %d", b.hist.total)
+	}
+}
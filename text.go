@@ -0,0 +1,98 @@
+package backoff
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MarshalText encodes the interval, max duration and jitter of the
+// backoff in the compact form "interval:max", such as "5m:6h", with
+// ":nojitter" appended if jitter is disabled. It implements
+// encoding.TextMarshaler, which makes it easy to configure a backoff
+// from an environment variable. Other settings are not encoded; use
+// MarshalJSON to encode the whole configuration.
+func (b *Backoff) MarshalText() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setup()
+	s := shortDuration(b.interval) + ":" + shortDuration(b.maxDuration)
+	if b.noJitter {
+		s += ":nojitter"
+	}
+
+	return []byte(s), nil
+}
+
+// UnmarshalText decodes a configuration encoded by MarshalText into the
+// backoff. Either duration may be left empty, as in ":1h", to use the
+// default, and the flags are a comma-separated list of which only
+// "nojitter" is currently recognised. As with UnmarshalJSON, settings
+// that aren't encoded are reset to their defaults, and the attempt
+// counter is left unchanged.
+func (b *Backoff) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("backoff: invalid backoff %q: want interval:max[:flags]", text)
+	}
+
+	nb := &Backoff{}
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", parts[0], &nb.interval},
+		{"max_duration", parts[1], &nb.maxDuration},
+	}
+
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("backoff: invalid %s: %v", d.name, err)
+		}
+		*d.dst = v
+	}
+
+	if len(parts) == 3 {
+		for _, flag := range strings.Split(parts[2], ",") {
+			switch flag {
+			case "nojitter":
+				nb.noJitter = true
+			default:
+				return fmt.Errorf("backoff: invalid flag %q", flag)
+			}
+		}
+	}
+
+	nb.setup()
+	if err := nb.validate(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setConfig(nb.config)
+	return nil
+}
+
+// shortDuration formats d like time.Duration.String, but without
+// trailing zero units, so that 5m0s is formatted as 5m.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return s
+}
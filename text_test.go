@@ -0,0 +1,91 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that the interval, max duration and jitter survive a round
+// trip through the text form.
+func TestTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		b    *Backoff
+		text string
+	}{
+		{New(0, 0), "5m:6h"},
+		{NewWithoutJitter(90*time.Minute, 1500*time.Millisecond), "1.5s:1h30m:nojitter"},
+		{New(time.Minute, time.Millisecond), "1ms:1m"},
+	}
+
+	for _, tc := range tests {
+		text, err := tc.b.MarshalText()
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+
+		if string(text) != tc.text {
+			t.Fatalf("expected %s, have %s", tc.text, text)
+		}
+
+		var nb Backoff
+		if err := nb.UnmarshalText(text); err != nil {
+			t.Fatalf("unmarshal of %s failed: %v", text, err)
+		}
+
+		if nb.config != tc.b.config {
+			t.Fatalf("expected %+v, have %+v", tc.b.config, nb.config)
+		}
+	}
+}
+
+// Empty durations should use the defaults.
+func TestTextDefaults(t *testing.T) {
+	var b Backoff
+	if err := b.UnmarshalText([]byte(":1h")); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if b.interval != DefaultInterval || b.maxDuration != time.Hour {
+		t.Fatalf("expected interval=%s, max=%s, have interval=%s, max=%s",
+			DefaultInterval, time.Hour, b.interval, b.maxDuration)
+	}
+
+	if !b.JitterEnabled() {
+		t.Fatal("expected jitter to be enabled")
+	}
+}
+
+// Ensure that UnmarshalText replaces the configuration consistently
+// with the source of randomness, as the text form doesn't include
+// SetSecureRandom.
+func TestTextSecureRandom(t *testing.T) {
+	b := New(time.Minute, time.Second)
+	b.SetSecureRandom()
+	if err := b.UnmarshalText([]byte("1s:1m")); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if _, ok := b.rng.(secureSource); ok || b.secureRandom {
+		t.Fatalf("expected the secure source to be replaced, have %T", b.rng)
+	}
+}
+
+// Malformed text should be rejected.
+func TestTextInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"5m",
+		"5m:6h:nojitter:extra",
+		"five:6h",
+		"5m:6h:fast",
+		"-5m:6h",
+		"1h:1h:nojitter,",
+	}
+
+	for _, text := range invalid {
+		var b Backoff
+		if err := b.UnmarshalText([]byte(text)); err == nil {
+			t.Fatalf("expected an error for %q", text)
+		}
+	}
+}